@@ -0,0 +1,76 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zodius/api-war/model"
+)
+
+const conquerChannel = "channel:conquer"
+
+type redisBroker struct {
+	client *redis.Client
+	// local fans the single redis subscription out to every subscriber of
+	// this process
+	local *memoryBroker
+	lock  *sync.Mutex
+	// listening is set once the redis subscription is confirmed
+	listening bool
+}
+
+// NewRedisBroker returns a broker backed by redis pub/sub, so events
+// published on one backend reach subscribers on all of them
+func NewRedisBroker(client *redis.Client) model.Broker {
+	return &redisBroker{
+		client: client,
+		local:  newMemoryBroker(),
+		lock:   new(sync.Mutex),
+	}
+}
+
+func (b *redisBroker) Publish(event model.ConquerEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(context.Background(), conquerChannel, payload).Err()
+}
+
+func (b *redisBroker) Subscribe(ctx context.Context) (<-chan model.ConquerEvent, error) {
+	if err := b.listen(ctx); err != nil {
+		return nil, err
+	}
+	return b.local.Subscribe(ctx)
+}
+
+// listen subscribes to the redis channel on the first call, the subscription
+// lasts as long as the process and go-redis reconnects it
+func (b *redisBroker) listen(ctx context.Context) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.listening {
+		return nil
+	}
+
+	pubsub := b.client.Subscribe(context.Background(), conquerChannel)
+	// wait for subscription confirmation
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return err
+	}
+	b.listening = true
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			var event model.ConquerEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				continue
+			}
+			b.local.Publish(event)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,60 @@
+package broker
+
+import (
+	"context"
+	"sync"
+
+	"github.com/zodius/api-war/model"
+)
+
+// subscriberBuffer is the number of pending events kept per subscriber,
+// events are dropped for subscribers which fall further behind
+const subscriberBuffer = 256
+
+type memoryBroker struct {
+	lock        *sync.Mutex
+	subscribers map[chan model.ConquerEvent]struct{}
+}
+
+// NewMemoryBroker returns an in-process broker, events only reach
+// subscribers of the same instance
+func NewMemoryBroker() model.Broker {
+	return newMemoryBroker()
+}
+
+func newMemoryBroker() *memoryBroker {
+	return &memoryBroker{
+		lock:        new(sync.Mutex),
+		subscribers: make(map[chan model.ConquerEvent]struct{}),
+	}
+}
+
+func (b *memoryBroker) Publish(event model.ConquerEvent) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			// drop event for slow subscriber
+		}
+	}
+	return nil
+}
+
+func (b *memoryBroker) Subscribe(ctx context.Context) (<-chan model.ConquerEvent, error) {
+	events := make(chan model.ConquerEvent, subscriberBuffer)
+
+	b.lock.Lock()
+	b.subscribers[events] = struct{}{}
+	b.lock.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.lock.Lock()
+		delete(b.subscribers, events)
+		b.lock.Unlock()
+		close(events)
+	}()
+	return events, nil
+}
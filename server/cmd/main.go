@@ -1,30 +1,95 @@
 package main
 
 import (
+	"context"
+	"log"
+	"net"
+	"os"
+
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
+	"github.com/zodius/api-war/broker"
+	"github.com/zodius/api-war/config"
+	"github.com/zodius/api-war/handler"
+	"github.com/zodius/api-war/handler/admin"
 	"github.com/zodius/api-war/handler/generic"
-	"github.com/zodius/api-war/handler/graphql"
-	"github.com/zodius/api-war/handler/restful"
+	"github.com/zodius/api-war/handler/grpc"
+	// protocols register themselves on import
+	_ "github.com/zodius/api-war/handler/graphql"
+	_ "github.com/zodius/api-war/handler/restful"
+	"github.com/zodius/api-war/limiter"
+	"github.com/zodius/api-war/model"
 	"github.com/zodius/api-war/repo"
+	"github.com/zodius/api-war/repo/memory"
 	"github.com/zodius/api-war/service"
 )
 
 func main() {
+	config, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := config.ApplyRateLimits(); err != nil {
+		log.Fatal(err)
+	}
+
 	app := gin.Default()
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: "redis:6379",
-		// Addr: "localhost:6379",
-	})
-	defer redisClient.Close()
+	if err := app.SetTrustedProxies(config.TrustedProxyList()); err != nil {
+		log.Fatal(err)
+	}
+	proxies, err := model.ParseTrustedProxies(config.TrustedProxyList())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var (
+		gameRepo    model.Repo
+		gameBroker  model.Broker
+		gameLimiter model.RateLimiter
+	)
+	switch config.Store {
+	case "redis":
+		redisClient := redis.NewClient(&redis.Options{
+			Addr: config.Redis.Addr,
+		})
+		defer redisClient.Close()
 
-	repo := repo.NewRepo(redisClient)
+		gameRepo = repo.NewRepo(redisClient, config.Map)
+		gameBroker = broker.NewRedisBroker(redisClient)
+		gameLimiter = limiter.NewRedisLimiter(redisClient)
+	case "memory":
+		// single instance only, state is lost on restart
+		gameRepo = memory.NewRepo()
+		gameBroker = broker.NewMemoryBroker()
+		gameLimiter = limiter.NewMemoryLimiter()
+	}
 
-	service := service.NewService(repo)
+	service := service.NewService(gameRepo, gameBroker, gameLimiter, config.Map)
+	if username, password, ok := config.AdminCredentials(); ok {
+		if err := service.BootstrapAdmin(username, password); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// every backend watches the round, freezing and archiving happen once
+	go service.WatchRounds(context.Background())
 
 	generic.RegisterHandler(service, app)
-	restful.RegisterHandler(service, app)
-	graphql.RegisterHandler(service, app)
+	admin.RegisterHandler(service, app)
+	handler.RegisterRoutes(service, app)
+
+	// gRPC battlefield runs alongside gin on its own port
+	grpcServer := grpc.NewServer(service, proxies)
+	listener, err := net.Listen("tcp", config.GRPCListen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go func() {
+		if err := grpcServer.Serve(listener); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	defer grpcServer.GracefulStop()
 
-	app.Run(":8971")
+	app.Run(config.Listen)
 }
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zodius/api-war/config"
+	// protocols register themselves on import
+	_ "github.com/zodius/api-war/handler/graphql"
+	_ "github.com/zodius/api-war/handler/grpc"
+	_ "github.com/zodius/api-war/handler/restful"
+	"github.com/zodius/api-war/repo"
+)
+
+// reconcile rebuilds user bitmaps and field counts from the field owner
+// hashes, the redis address comes from the server config
+func main() {
+	config, err := config.LoadFlags(flag.NewFlagSet("reconcile", flag.ExitOnError), os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if config.Store != "redis" {
+		log.Fatalf("reconcile needs the redis store, got %q", config.Store)
+	}
+
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: config.Redis.Addr,
+	})
+	defer redisClient.Close()
+
+	if err := repo.Reconcile(redisClient); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("reconciled")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zodius/api-war/config"
+	// protocols register themselves on import
+	_ "github.com/zodius/api-war/handler/graphql"
+	_ "github.com/zodius/api-war/handler/grpc"
+	_ "github.com/zodius/api-war/handler/restful"
+	"github.com/zodius/api-war/repo"
+)
+
+// replay rebuilds the field owners, user bitmaps and scores from the conquer
+// event log into an empty redis database and compares them with the live
+// state, the live game is at the redis address of the server config
+func main() {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	targetAddr := flags.String("target", "", "redis address to rebuild into, defaults to -redis.addr")
+	targetDB := flags.Int("target-db", 1, "empty redis database to rebuild into")
+	verifyOnly := flags.Bool("verify-only", false, "compare an earlier rebuild with the live state without replaying")
+	config, err := config.LoadFlags(flags, os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if config.Store != "redis" {
+		log.Fatalf("replay needs the redis store, got %q", config.Store)
+	}
+
+	if *targetAddr == "" {
+		*targetAddr = config.Redis.Addr
+	}
+
+	live := redis.NewClient(&redis.Options{
+		Addr: config.Redis.Addr,
+	})
+	defer live.Close()
+	target := redis.NewClient(&redis.Options{
+		Addr: *targetAddr,
+		DB:   *targetDB,
+	})
+	defer target.Close()
+
+	if !*verifyOnly {
+		events, err := repo.Replay(live, target)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("replayed %d events", events)
+	}
+
+	// conquers during the replay show up as differences, run it while the
+	// game is paused for an exact result
+	differences, err := repo.Verify(live, target)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, difference := range differences {
+		log.Println(difference)
+	}
+	if len(differences) > 0 {
+		log.Fatalf("rebuilt state differs from the live state")
+	}
+	log.Println("rebuilt state matches the live state")
+}
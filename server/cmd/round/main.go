@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zodius/api-war/broker"
+	"github.com/zodius/api-war/config"
+	// protocols register themselves on import
+	_ "github.com/zodius/api-war/handler/graphql"
+	_ "github.com/zodius/api-war/handler/grpc"
+	_ "github.com/zodius/api-war/handler/restful"
+	"github.com/zodius/api-war/limiter"
+	"github.com/zodius/api-war/model"
+	"github.com/zodius/api-war/repo"
+	"github.com/zodius/api-war/service"
+)
+
+// round shows the current round, or schedules one when -start and -end are
+// given, times are RFC 3339. The redis address comes from the server config.
+func main() {
+	flags := flag.NewFlagSet("round", flag.ExitOnError)
+	start := flags.String("start", "", "round start time")
+	end := flags.String("end", "", "round end time")
+	freeze := flags.String("freeze", "", "scoreboard freeze time, empty for no freeze")
+	scoring := flags.String("scoring", string(model.ScoringConquers), "scoreboard order, conquers or holdTime")
+	config, err := config.LoadFlags(flags, os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if config.Store != "redis" {
+		log.Fatalf("round needs the redis store, got %q", config.Store)
+	}
+
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: config.Redis.Addr,
+	})
+	defer redisClient.Close()
+
+	service := service.NewService(
+		repo.NewRepo(redisClient, config.Map),
+		broker.NewRedisBroker(redisClient),
+		limiter.NewRedisLimiter(redisClient),
+		config.Map,
+	)
+
+	if *start == "" && *end == "" {
+		round, err := service.GetRound()
+		if err != nil {
+			if errors.Is(err, model.ErrNotFound) {
+				log.Fatal("no round configured")
+			}
+			log.Fatal(err)
+		}
+		printJSON(round)
+		return
+	}
+
+	round := model.Round{Scoring: model.Scoring(*scoring)}
+	if round.StartAt, err = time.Parse(time.RFC3339, *start); err != nil {
+		log.Fatalf("invalid -start: %v", err)
+	}
+	if round.EndAt, err = time.Parse(time.RFC3339, *end); err != nil {
+		log.Fatalf("invalid -end: %v", err)
+	}
+	if *freeze != "" {
+		if round.FreezeAt, err = time.Parse(time.RFC3339, *freeze); err != nil {
+			log.Fatalf("invalid -freeze: %v", err)
+		}
+	}
+
+	round, err = service.SetRound(round)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printJSON(round)
+}
+
+func printJSON(v interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		log.Fatal(err)
+	}
+}
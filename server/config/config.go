@@ -0,0 +1,335 @@
+// Package config loads the server settings from a YAML file, environment
+// variables and flags, later sources override earlier ones.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zodius/api-war/model"
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix prefixes every environment variable, e.g. APIWAR_REDIS_ADDR
+const envPrefix = "APIWAR_"
+
+// maxFieldCount keeps field ids within the int32 field ids of the grpc api,
+// which also keeps them within redis bitmap offsets
+const maxFieldCount = math.MaxInt32
+
+type Config struct {
+	Listen     string          `yaml:"listen"`
+	GRPCListen string          `yaml:"grpcListen"`
+	Store      string          `yaml:"store"`
+	Redis      RedisConfig     `yaml:"redis"`
+	Map        model.MapConfig `yaml:"map"`
+	// Admin is <username>:<password> of a user granted the admin role on startup
+	Admin string `yaml:"admin"`
+	// RateLimits is keyed by protocol name, see model.ParseRateLimit for the format
+	RateLimits map[string]RateLimitConfig `yaml:"rateLimits"`
+	// TrustedProxies is a comma separated list of the addresses or CIDR
+	// ranges of the proxies in front of the backends, only they may report
+	// client addresses for the per address rate limits
+	TrustedProxies string `yaml:"trustedProxies"`
+}
+
+type RedisConfig struct {
+	Addr string `yaml:"addr"`
+}
+
+type RateLimitConfig struct {
+	User string `yaml:"user"`
+	IP   string `yaml:"ip"`
+}
+
+// Default returns the settings used when nothing else is configured, rate
+// limits default to the registered protocols
+func Default() Config {
+	config := Config{
+		Listen:     ":8971",
+		GRPCListen: ":8972",
+		Store:      "redis",
+		Redis: RedisConfig{
+			Addr: "redis:6379",
+		},
+		Map: model.MapConfig{
+			FieldCount: model.DefaultFieldCount,
+			BatchSize:  model.DefaultBatchSize,
+			// batches larger than the burst of a rate limit are always denied
+			ConquerBatchSize: model.DefaultConquerBatchSize,
+		},
+		RateLimits: make(map[string]RateLimitConfig),
+	}
+	for _, protocol := range model.Protocols() {
+		config.RateLimits[protocol.Name] = RateLimitConfig{
+			User: protocol.RateLimit.String(),
+			IP:   protocol.IPRateLimit.String(),
+		}
+	}
+	return config
+}
+
+// Load reads the config file named by -config or APIWAR_CONFIG, then
+// environment variables, then the flags in args, and validates the result
+func Load(args []string, getenv func(string) string) (Config, error) {
+	return LoadFlags(flag.NewFlagSet("api-war", flag.ContinueOnError), args, getenv)
+}
+
+// LoadFlags is Load for commands with flags of their own defined on flags,
+// they are set once LoadFlags returns
+func LoadFlags(flags *flag.FlagSet, args []string, getenv func(string) string) (Config, error) {
+	config := Default()
+
+	path := flags.String("config", getenv(envPrefix+"CONFIG"), "YAML config file")
+	overrides := bind(flags, config)
+	if err := flags.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if *path != "" {
+		file, err := os.Open(*path)
+		if err != nil {
+			return Config{}, err
+		}
+		err = config.read(file)
+		file.Close()
+		if err != nil {
+			return Config{}, fmt.Errorf("config %s: %w", *path, err)
+		}
+	}
+
+	for name, value := range overrides {
+		if env := getenv(envName(name)); env != "" {
+			if err := value(&config, env); err != nil {
+				return Config{}, fmt.Errorf("%s: %w", envName(name), err)
+			}
+		}
+	}
+
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		value, ok := overrides[f.Name]
+		if !ok || err != nil {
+			return
+		}
+		if setErr := value(&config, f.Value.String()); setErr != nil {
+			err = fmt.Errorf("-%s: %w", f.Name, setErr)
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+func (c *Config) read(r io.Reader) error {
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// override sets a config value from its environment variable or flag
+type override func(config *Config, value string) error
+
+// bind defines a flag for every setting, named like the environment variable
+// in lower case with dots, e.g. -redis.addr and APIWAR_REDIS_ADDR
+func bind(flags *flag.FlagSet, defaults Config) map[string]override {
+	overrides := map[string]override{
+		"listen":      func(c *Config, v string) error { c.Listen = v; return nil },
+		"grpc.listen": func(c *Config, v string) error { c.GRPCListen = v; return nil },
+		"store":       func(c *Config, v string) error { c.Store = v; return nil },
+		"redis.addr":  func(c *Config, v string) error { c.Redis.Addr = v; return nil },
+		"map.fieldcount": func(c *Config, v string) (err error) {
+			c.Map.FieldCount, err = strconv.Atoi(v)
+			return err
+		},
+		"map.batchsize": func(c *Config, v string) (err error) {
+			c.Map.BatchSize, err = strconv.Atoi(v)
+			return err
+		},
+		"map.conquerbatchsize": func(c *Config, v string) (err error) {
+			c.Map.ConquerBatchSize, err = strconv.Atoi(v)
+			return err
+		},
+		"map.lock.duration": func(c *Config, v string) (err error) {
+			c.Map.Lock.Duration, err = time.ParseDuration(v)
+			return err
+		},
+		"map.lock.firstcapture": func(c *Config, v string) (err error) {
+			c.Map.Lock.FirstCapture, err = time.ParseDuration(v)
+			return err
+		},
+		"admin":          func(c *Config, v string) error { c.Admin = v; return nil },
+		"trustedproxies": func(c *Config, v string) error { c.TrustedProxies = v; return nil },
+	}
+	usage := map[string]string{
+		"listen":                "HTTP listen address",
+		"grpc.listen":           "gRPC listen address",
+		"store":                 "game state store, memory or redis",
+		"redis.addr":            "redis address",
+		"map.fieldcount":        "number of fields on the map",
+		"map.batchsize":         "fields read from redis per request when loading the map",
+		"map.conquerbatchsize":  "most fields a batch conquer can take",
+		"map.lock.duration":     "how long a field can't be retaken after a conquer, 0 disables the lock",
+		"map.lock.firstcapture": "lock after conquering a free field, 0 uses map.lock.duration",
+		"trustedproxies":        "comma separated addresses or CIDR ranges of the proxies in front of the backends, empty trusts none",
+		"admin":                 "grant the admin role on startup, as <username>:<password>, the user is created if missing",
+	}
+	values := map[string]string{
+		"listen":                defaults.Listen,
+		"grpc.listen":           defaults.GRPCListen,
+		"store":                 defaults.Store,
+		"redis.addr":            defaults.Redis.Addr,
+		"map.fieldcount":        strconv.Itoa(defaults.Map.FieldCount),
+		"map.batchsize":         strconv.Itoa(defaults.Map.BatchSize),
+		"map.conquerbatchsize":  strconv.Itoa(defaults.Map.ConquerBatchSize),
+		"map.lock.duration":     defaults.Map.Lock.Duration.String(),
+		"map.lock.firstcapture": defaults.Map.Lock.FirstCapture.String(),
+		"admin":                 defaults.Admin,
+		"trustedproxies":        defaults.TrustedProxies,
+	}
+
+	for name, limits := range defaults.RateLimits {
+		protocol := name
+		overrides["ratelimit."+protocol] = func(c *Config, v string) error {
+			limits := c.RateLimits[protocol]
+			limits.User = v
+			c.RateLimits[protocol] = limits
+			return nil
+		}
+		overrides["ipratelimit."+protocol] = func(c *Config, v string) error {
+			limits := c.RateLimits[protocol]
+			limits.IP = v
+			c.RateLimits[protocol] = limits
+			return nil
+		}
+		usage["ratelimit."+protocol] = "conquers per second per user over " + protocol + ", as <rate>:<burst>, 0 disables"
+		usage["ipratelimit."+protocol] = "conquers per second per client address over " + protocol + ", as <rate>:<burst>, 0 disables"
+		values["ratelimit."+protocol] = limits.User
+		values["ipratelimit."+protocol] = limits.IP
+	}
+
+	for name := range overrides {
+		flags.String(name, values[name], usage[name]+" ("+envName(name)+")")
+	}
+	return overrides
+}
+
+// envName maps a flag name to its environment variable
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, ".", "_"))
+}
+
+// Validate checks every setting, so a bad config fails at startup
+func (c Config) Validate() error {
+	var errs []error
+	if c.Listen == "" {
+		errs = append(errs, errors.New("listen address is required"))
+	}
+	if c.GRPCListen == "" {
+		errs = append(errs, errors.New("gRPC listen address is required"))
+	}
+	switch c.Store {
+	case "redis":
+		if c.Redis.Addr == "" {
+			errs = append(errs, errors.New("redis address is required for the redis store"))
+		}
+	case "memory":
+	default:
+		errs = append(errs, fmt.Errorf("unknown store %q, expected memory or redis", c.Store))
+	}
+	if c.Map.FieldCount < 1 || c.Map.FieldCount > maxFieldCount {
+		errs = append(errs, fmt.Errorf("field count must be between 1 and %d, got %d", maxFieldCount, c.Map.FieldCount))
+	}
+	if c.Map.BatchSize < 1 {
+		errs = append(errs, fmt.Errorf("batch size must be positive, got %d", c.Map.BatchSize))
+	}
+	if c.Map.ConquerBatchSize < 1 {
+		errs = append(errs, fmt.Errorf("conquer batch size must be positive, got %d", c.Map.ConquerBatchSize))
+	}
+	if c.Map.Lock.Duration < 0 || c.Map.Lock.FirstCapture < 0 {
+		errs = append(errs, fmt.Errorf("field locks must not be negative, got %s and %s", c.Map.Lock.Duration, c.Map.Lock.FirstCapture))
+	}
+	if c.Admin != "" {
+		if username, password, ok := strings.Cut(c.Admin, ":"); !ok || username == "" || password == "" {
+			errs = append(errs, errors.New("admin must be <username>:<password>"))
+		}
+	}
+	if _, err := model.ParseTrustedProxies(c.TrustedProxyList()); err != nil {
+		errs = append(errs, err)
+	}
+	for name, limits := range c.RateLimits {
+		if _, ok := model.GetProtocol(name); !ok {
+			errs = append(errs, fmt.Errorf("rate limit for %w %q", model.ErrUnknownProtocol, name))
+			continue
+		}
+		for kind, value := range map[string]string{"user": limits.User, "ip": limits.IP} {
+			limit, err := model.ParseRateLimit(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s %s rate limit: %w", name, kind, err))
+				continue
+			}
+			// a larger batch would always be denied
+			if limit.Enabled() && c.Map.ConquerBatchSize > limit.Burst {
+				errs = append(errs, fmt.Errorf("conquer batch size %d is above the %s %s rate limit burst of %d",
+					c.Map.ConquerBatchSize, name, kind, limit.Burst))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// TrustedProxyList splits TrustedProxies
+func (c Config) TrustedProxyList() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(c.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// AdminCredentials splits Admin, ok is false if no admin is configured
+func (c Config) AdminCredentials() (username, password string, ok bool) {
+	if c.Admin == "" {
+		return "", "", false
+	}
+	username, password, _ = strings.Cut(c.Admin, ":")
+	return username, password, true
+}
+
+// ApplyRateLimits updates the registered protocols with the configured limits
+func (c Config) ApplyRateLimits() error {
+	for name, limits := range c.RateLimits {
+		protocol, ok := model.GetProtocol(name)
+		if !ok {
+			return fmt.Errorf("%w: %s", model.ErrUnknownProtocol, name)
+		}
+		var err error
+		if protocol.RateLimit, err = model.ParseRateLimit(limits.User); err != nil {
+			return err
+		}
+		if protocol.IPRateLimit, err = model.ParseRateLimit(limits.IP); err != nil {
+			return err
+		}
+		if err := model.UpdateProtocol(protocol); err != nil {
+			return err
+		}
+	}
+	return nil
+}
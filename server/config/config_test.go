@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zodius/api-war/model"
+)
+
+func init() {
+	if _, ok := model.GetProtocol("restful"); !ok {
+		model.RegisterProtocol(model.Protocol{
+			Name:      "restful",
+			RateLimit: model.RateLimit{Rate: 20, Burst: 40},
+		})
+	}
+}
+
+func env(values map[string]string) func(string) string {
+	return func(name string) string {
+		return values[name]
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	config, err := Load(nil, env(nil))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if config.Listen != ":8971" || config.GRPCListen != ":8972" || config.Redis.Addr != "redis:6379" {
+		t.Errorf("defaults = %+v", config)
+	}
+	if config.Map.FieldCount != model.DefaultFieldCount || config.Map.BatchSize != model.DefaultBatchSize ||
+		config.Map.ConquerBatchSize != model.DefaultConquerBatchSize {
+		t.Errorf("default map = %+v", config.Map)
+	}
+	if limits := config.RateLimits["restful"]; limits.User != "20:40" || limits.IP != "0" {
+		t.Errorf("default restful limits = %+v", limits)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+listen: ":9000"
+store: memory
+redis:
+  addr: file:6379
+map:
+  fieldCount: 10000
+  batchSize: 500
+  conquerBatchSize: 1
+  lock:
+    duration: 2s
+    firstCapture: 10s
+rateLimits:
+  restful:
+    user: "5:10"
+    ip: "50:100"
+`)
+
+	config, err := Load(
+		[]string{"-config", path, "-map.fieldcount", "20000"},
+		env(map[string]string{
+			"APIWAR_REDIS_ADDR":            "env:6379",
+			"APIWAR_MAP_FIELDCOUNT":        "30000",
+			"APIWAR_RATELIMIT_RESTFUL":     "1:1",
+			"APIWAR_MAP_LOCK_FIRSTCAPTURE": "30s",
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// file
+	if config.Listen != ":9000" || config.Store != "memory" || config.Map.BatchSize != 500 || config.Map.ConquerBatchSize != 1 {
+		t.Errorf("file values not applied: %+v", config)
+	}
+	if config.RateLimits["restful"].IP != "50:100" {
+		t.Errorf("file ip rate limit = %q", config.RateLimits["restful"].IP)
+	}
+	// env over file
+	if config.Redis.Addr != "env:6379" {
+		t.Errorf("redis addr = %q, want env:6379", config.Redis.Addr)
+	}
+	if config.RateLimits["restful"].User != "1:1" {
+		t.Errorf("user rate limit = %q, want 1:1", config.RateLimits["restful"].User)
+	}
+	if config.Map.Lock.Duration != 2*time.Second || config.Map.Lock.FirstCapture != 30*time.Second {
+		t.Errorf("field lock = %+v, want 2s from file and 30s from env", config.Map.Lock)
+	}
+	// flag over env
+	if config.Map.FieldCount != 20000 {
+		t.Errorf("field count = %d, want 20000", config.Map.FieldCount)
+	}
+	// untouched settings keep their default
+	if config.GRPCListen != ":8972" {
+		t.Errorf("grpc listen = %q, want default", config.GRPCListen)
+	}
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	path := writeConfig(t, "map:\n  fieldCount: 10000\n")
+	config, err := Load(nil, env(map[string]string{"APIWAR_CONFIG": path}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if config.Map.FieldCount != 10000 {
+		t.Errorf("field count = %d, want 10000", config.Map.FieldCount)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	path := writeConfig(t, "fieldCount: 10000\n")
+	if _, err := Load([]string{"-config", path}, env(nil)); err == nil {
+		t.Error("unknown key accepted")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for name, tt := range map[string]struct {
+		args []string
+		want string
+	}{
+		"store":         {[]string{"-store", "disk"}, "unknown store"},
+		"field count":   {[]string{"-map.fieldcount", "0"}, "field count"},
+		"grpc field id": {[]string{"-map.fieldcount", "2147483648"}, "field count"},
+		"batch size":    {[]string{"-map.batchsize", "-1"}, "batch size"},
+		"field lock":    {[]string{"-map.lock.duration", "-1s"}, "field locks"},
+		"conquer batch": {[]string{"-map.conquerbatchsize", "0"}, "conquer batch size"},
+		"proxy":         {[]string{"-trustedproxies", "10.0.0.1,nginx"}, "trusted proxy"},
+		"redis address": {[]string{"-redis.addr", ""}, "redis address"},
+		"admin":         {[]string{"-admin", "root"}, "admin"},
+		"rate limit":    {[]string{"-ratelimit.restful", "fast"}, "rate limit"},
+		"zero rate":     {[]string{"-ratelimit.restful", "0:5"}, "rate must be a positive number"},
+		"batch burst":   {[]string{"-ipratelimit.restful", "1:10"}, "above the restful ip rate limit burst"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(tt.args, env(nil))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load(%v) error = %v, want %q", tt.args, err, tt.want)
+			}
+		})
+	}
+
+	config := Default()
+	config.RateLimits["soap"] = RateLimitConfig{}
+	if err := config.Validate(); !errors.Is(err, model.ErrUnknownProtocol) {
+		t.Errorf("Validate with unknown protocol: err = %v, want ErrUnknownProtocol", err)
+	}
+}
+
+func TestApplyRateLimits(t *testing.T) {
+	original, _ := model.GetProtocol("restful")
+	defer model.UpdateProtocol(original)
+
+	config, err := Load([]string{"-ratelimit.restful", "2:3", "-ipratelimit.restful", "0", "-map.conquerbatchsize", "3"}, env(nil))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := config.ApplyRateLimits(); err != nil {
+		t.Fatalf("ApplyRateLimits: %v", err)
+	}
+	protocol, _ := model.GetProtocol("restful")
+	if protocol.RateLimit != (model.RateLimit{Rate: 2, Burst: 3}) || protocol.IPRateLimit.Enabled() {
+		t.Errorf("protocol = %+v", protocol)
+	}
+}
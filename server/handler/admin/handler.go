@@ -0,0 +1,166 @@
+package admin
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zodius/api-war/model"
+)
+
+type Handler struct {
+	Service model.Service
+}
+
+func RegisterHandler(service model.Service, app *gin.Engine) {
+	handler := Handler{
+		Service: service,
+	}
+
+	admin := app.Group("/admin", handler.RequireToken)
+
+	admin.GET("/users", handler.ListUsers)
+	admin.GET("/users/:username/sessions", handler.GetUserSessions)
+	admin.POST("/users/:username/ban", handler.BanUser)
+	admin.DELETE("/users/:username/ban", handler.UnbanUser)
+	admin.DELETE("/users/:username/fields", handler.WipeUser)
+	admin.PUT("/fields/:type/:id", handler.SetFieldOwner)
+	admin.GET("/round", handler.GetRound)
+	admin.PUT("/round", handler.SetRound)
+	admin.GET("/audit", handler.GetAuditLog)
+}
+
+// RequireToken rejects requests without a token, the service checks the role
+func (h *Handler) RequireToken(c *gin.Context) {
+	if c.GetHeader("X-Api-Token") == "" {
+		c.AbortWithStatusJSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+	c.Next()
+}
+
+// adminError converts service errors of admin calls to responses
+func adminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrUnauthorized):
+		c.JSON(401, gin.H{"error": "unauthorized"})
+	case errors.Is(err, model.ErrForbidden):
+		c.JSON(403, gin.H{"error": "forbidden"})
+	case errors.Is(err, model.ErrNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrUnknownProtocol), errors.Is(err, model.ErrInvalidRound),
+		errors.Is(err, model.ErrFieldOutOfRange):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
+
+func (h *Handler) ListUsers(c *gin.Context) {
+	users, err := h.Service.AdminListUsers(c.GetHeader("X-Api-Token"))
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"users": users})
+}
+
+func (h *Handler) GetUserSessions(c *gin.Context) {
+	sessions, err := h.Service.AdminGetUserSessions(c.GetHeader("X-Api-Token"), c.Param("username"))
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"sessions": sessions})
+}
+
+func (h *Handler) BanUser(c *gin.Context) {
+	if err := h.Service.AdminBanUser(c.GetHeader("X-Api-Token"), c.Param("username")); err != nil {
+		adminError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{})
+}
+
+func (h *Handler) UnbanUser(c *gin.Context) {
+	if err := h.Service.AdminUnbanUser(c.GetHeader("X-Api-Token"), c.Param("username")); err != nil {
+		adminError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{})
+}
+
+func (h *Handler) WipeUser(c *gin.Context) {
+	if err := h.Service.AdminWipeUser(c.GetHeader("X-Api-Token"), c.Param("username")); err != nil {
+		adminError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{})
+}
+
+func (h *Handler) SetFieldOwner(c *gin.Context) {
+	type request struct {
+		// empty owner frees the field
+		Owner string `json:"owner"`
+	}
+
+	fieldID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "field id must be integer"})
+		return
+	}
+
+	var req request
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Service.AdminSetFieldOwner(c.GetHeader("X-Api-Token"), fieldID, c.Param("type"), req.Owner); err != nil {
+		adminError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{})
+}
+
+func (h *Handler) GetRound(c *gin.Context) {
+	round, err := h.Service.AdminGetRound(c.GetHeader("X-Api-Token"))
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+	c.JSON(200, round)
+}
+
+func (h *Handler) SetRound(c *gin.Context) {
+	var req model.Round
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	round, err := h.Service.AdminSetRound(c.GetHeader("X-Api-Token"), req)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+	c.JSON(200, round)
+}
+
+func (h *Handler) GetAuditLog(c *gin.Context) {
+	limit := 0
+	if limitParam := c.Query("limit"); limitParam != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitParam); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid limit parameter"})
+			return
+		}
+	}
+
+	entries, err := h.Service.AdminGetAuditLog(c.GetHeader("X-Api-Token"), limit)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"entries": entries})
+}
@@ -1,10 +1,17 @@
 package generic
 
 import (
+	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/zodius/api-war/model"
 )
 
@@ -18,8 +25,28 @@ func RegisterHandler(service model.Service, app *gin.Engine) {
 	}
 
 	app.GET("/scoreboard", handler.CorsMiddleware(), handler.GetScoreboard)
+	app.GET("/scoreboard/me", handler.CorsMiddleware(), handler.GetMyScore)
 	app.GET("/me", handler.CorsMiddleware(), handler.GetMe)
+	app.GET("/sessions", handler.CorsMiddleware(), handler.GetSessions)
 	app.GET("/map", handler.CorsMiddleware(), handler.GetMap)
+	app.GET("/map/size", handler.CorsMiddleware(), handler.GetMapSize)
+	app.GET("/map/snapshot", handler.CorsMiddleware(), handler.GetMapSnapshot)
+	app.GET("/map/changes", handler.CorsMiddleware(), handler.GetMapChanges)
+	app.GET("/fields/:id/history", handler.CorsMiddleware(), handler.GetFieldHistory)
+	app.GET("/teams", handler.CorsMiddleware(), handler.GetTeams)
+	app.POST("/teams", handler.CorsMiddleware(), handler.CreateTeam)
+	app.GET("/teams/scoreboard", handler.CorsMiddleware(), handler.GetTeamScoreboard)
+	app.GET("/teams/:id", handler.CorsMiddleware(), handler.GetTeam)
+	app.POST("/teams/:id/invites", handler.CorsMiddleware(), handler.InviteToTeam)
+	app.POST("/teams/:id/join", handler.CorsMiddleware(), handler.JoinTeam)
+	app.GET("/round", handler.CorsMiddleware(), handler.GetRound)
+	app.GET("/rounds/:id/scoreboard", handler.CorsMiddleware(), handler.GetRoundScoreboard)
+	app.GET("/ws", handler.FieldUpdates)
+}
+
+var upgrader = websocket.Upgrader{
+	// same policy as CorsMiddleware
+	CheckOrigin: func(r *http.Request) bool { return true },
 }
 
 // temporary middleware to disable CORS
@@ -46,15 +73,312 @@ func (h *Handler) GetMe(c *gin.Context) {
 	c.JSON(200, gin.H{"username": username})
 }
 
+func (h *Handler) GetSessions(c *gin.Context) {
+	token := c.GetHeader("X-Api-Token")
+	sessions, err := h.Service.GetSessions(token)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			c.JSON(401, gin.H{"error": "unauthorized"})
+		} else {
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(200, gin.H{"sessions": sessions})
+}
+
+// defaultNeighbours is the number of scores shown on each side of the caller
+const defaultNeighbours = 5
+
 func (h *Handler) GetScoreboard(c *gin.Context) {
-	scoreList, err := h.Service.GetScoreboard()
+	offset, limit, ok := pageQuery(c)
+	if !ok {
+		return
+	}
+	scoreList, err := h.Service.GetScoreboard(offset, limit)
+	if err != nil {
+		scoreboardError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"scoreList": scoreList})
+}
+
+func (h *Handler) GetMyScore(c *gin.Context) {
+	around, ok := intQuery(c, "around", defaultNeighbours)
+	if !ok {
+		return
+	}
+	token := c.GetHeader("X-Api-Token")
+	me, err := h.Service.GetMyScore(token, around)
+	if err != nil {
+		scoreboardError(c, err)
+		return
+	}
+	c.JSON(200, me)
+}
+
+// pageQuery reads the offset and limit parameters, a missing limit is 0 so
+// the service applies its default
+func pageQuery(c *gin.Context) (offset, limit int, ok bool) {
+	if offset, ok = intQuery(c, "offset", 0); !ok {
+		return 0, 0, false
+	}
+	if limit, ok = intQuery(c, "limit", 0); !ok {
+		return 0, 0, false
+	}
+	return offset, limit, true
+}
+
+// intQuery reads an optional integer parameter, it replies 400 if the value
+// is not a number
+func intQuery(c *gin.Context, name string, fallback int) (int, bool) {
+	param := c.Query(name)
+	if param == "" {
+		return fallback, true
+	}
+	value, err := strconv.Atoi(param)
 	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid " + name + " parameter"})
+		return 0, false
+	}
+	return value, true
+}
+
+func scoreboardError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrInvalidPage):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrUnauthorized):
+		c.JSON(401, gin.H{"error": "unauthorized"})
+	case errors.Is(err, model.ErrNotFound):
+		c.JSON(404, gin.H{"error": "no score"})
+	default:
 		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
+
+func (h *Handler) GetRound(c *gin.Context) {
+	round, err := h.Service.GetRound()
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			c.JSON(404, gin.H{"error": "no round configured"})
+		} else {
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(200, round)
+}
+
+func (h *Handler) GetRoundScoreboard(c *gin.Context) {
+	roundID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid round id"})
+		return
+	}
+
+	offset, limit, ok := pageQuery(c)
+	if !ok {
+		return
+	}
+
+	scoreList, err := h.Service.GetRoundScoreboard(roundID, offset, limit)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			c.JSON(404, gin.H{"error": "round not archived"})
+		} else {
+			scoreboardError(c, err)
+		}
 		return
 	}
 	c.JSON(200, gin.H{"scoreList": scoreList})
 }
 
+func (h *Handler) GetTeams(c *gin.Context) {
+	teams, err := h.Service.GetTeams()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"teams": teams})
+}
+
+func (h *Handler) GetTeam(c *gin.Context) {
+	teamID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid team id"})
+		return
+	}
+	team, err := h.Service.GetTeam(teamID)
+	if err != nil {
+		teamError(c, err)
+		return
+	}
+	c.JSON(200, team)
+}
+
+func (h *Handler) CreateTeam(c *gin.Context) {
+	type request struct {
+		Name string `json:"name"`
+	}
+
+	var req request
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	team, err := h.Service.CreateTeam(c.GetHeader("X-Api-Token"), req.Name)
+	if err != nil {
+		teamError(c, err)
+		return
+	}
+	c.JSON(200, team)
+}
+
+func (h *Handler) InviteToTeam(c *gin.Context) {
+	type request struct {
+		Username string `json:"username"`
+	}
+
+	teamID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid team id"})
+		return
+	}
+	var req request
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Service.InviteToTeam(c.GetHeader("X-Api-Token"), teamID, req.Username); err != nil {
+		teamError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{})
+}
+
+func (h *Handler) JoinTeam(c *gin.Context) {
+	teamID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid team id"})
+		return
+	}
+	team, err := h.Service.JoinTeam(c.GetHeader("X-Api-Token"), teamID)
+	if err != nil {
+		teamError(c, err)
+		return
+	}
+	c.JSON(200, team)
+}
+
+func (h *Handler) GetTeamScoreboard(c *gin.Context) {
+	offset, limit, ok := pageQuery(c)
+	if !ok {
+		return
+	}
+	scoreList, err := h.Service.GetTeamScoreboard(offset, limit)
+	if err != nil {
+		scoreboardError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"scoreList": scoreList})
+}
+
+func teamError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrInvalidTeam):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrUnauthorized):
+		c.JSON(401, gin.H{"error": "unauthorized"})
+	case errors.Is(err, model.ErrNotTeamMember), errors.Is(err, model.ErrNotInvited):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrNotFound):
+		c.JSON(404, gin.H{"error": "team or user not found"})
+	case errors.Is(err, model.ErrTeamExist), errors.Is(err, model.ErrAlreadyInTeam), errors.Is(err, model.ErrTeamFull):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
+
+func (h *Handler) GetFieldHistory(c *gin.Context) {
+	fieldID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid field id"})
+		return
+	}
+
+	history, err := h.Service.GetFieldHistory(fieldID)
+	if err != nil {
+		if errors.Is(err, model.ErrFieldOutOfRange) {
+			c.JSON(400, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(200, gin.H{"fieldID": fieldID, "history": history})
+}
+
+func (h *Handler) GetMapSize(c *gin.Context) {
+	c.JSON(200, gin.H{"fieldCount": h.Service.GetFieldCount()})
+}
+
+// GetMapSnapshot serves the whole map in the binary format of
+// model.MapSnapshot, clients revalidate with the ETag
+func (h *Handler) GetMapSnapshot(c *gin.Context) {
+	snapshot, version, err := h.Service.GetMapSnapshot()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	etag := fmt.Sprintf(`"%d"`, version)
+	c.Header("ETag", etag)
+	c.Header("X-Map-Version", strconv.FormatUint(version, 10))
+	c.Header("Vary", "Accept-Encoding")
+	c.Header("Access-Control-Expose-Headers", "ETag, X-Map-Version")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(304)
+		return
+	}
+
+	if !strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") {
+		c.Data(200, "application/octet-stream", snapshot)
+		return
+	}
+	c.Header("Content-Encoding", "gzip")
+	c.Header("Content-Type", "application/octet-stream")
+	c.Status(200)
+	writer, _ := gzip.NewWriterLevel(c.Writer, gzip.BestSpeed)
+	writer.Write(snapshot)
+	writer.Close()
+}
+
+// GetMapChanges returns the field changes after the since cursor, clients
+// start from the version of a snapshot and continue from the returned version
+func (h *Handler) GetMapChanges(c *gin.Context) {
+	since, err := strconv.ParseUint(c.Query("since"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid since parameter"})
+		return
+	}
+
+	changes, err := h.Service.GetMapChanges(since)
+	if err != nil {
+		var resync *model.ResyncRequiredError
+		if errors.As(err, &resync) {
+			c.JSON(410, gin.H{"error": "resync required", "version": resync.Version})
+		} else {
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(200, changes)
+}
+
 func (h *Handler) GetMap(c *gin.Context) {
 	startPos := 0
 	endPos := 0
@@ -74,15 +398,7 @@ func (h *Handler) GetMap(c *gin.Context) {
 			c.JSON(400, gin.H{"error": "Invalid end parameter"})
 			return
 		}
-		if start > end {
-			c.JSON(400, gin.H{"error": "Invalid start and end parameter"})
-			return
-		}
-		if start <= 0 || end > model.FieldCount {
-			c.JSON(400, gin.H{"error": "Invalid start and end parameter"})
-			return
-		}
-		if start > end {
+		if start <= 0 || start > end {
 			c.JSON(400, gin.H{"error": "Invalid start and end parameter"})
 			return
 		}
@@ -94,11 +410,82 @@ func (h *Handler) GetMap(c *gin.Context) {
 	}
 
 	fmt.Println(startPos, endPos)
-	mapObject, err := h.Service.GetCurrentMap(startPos, endPos)
+	var mapObject model.Map
+	var err error
+	if startPos == 0 {
+		// no range, the whole map
+		mapObject, err = h.Service.GetWholeMap()
+	} else {
+		mapObject, err = h.Service.GetCurrentMap(startPos, endPos)
+	}
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		if errors.Is(err, model.ErrInvalidRange) {
+			c.JSON(400, gin.H{"error": "Invalid start and end parameter"})
+		} else {
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
+	// teams=true adds the team of every owner
+	if c.Query("teams") == "true" {
+		teams, err := h.Service.GetTeams()
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, mapObject.TeamRepresentation(model.TeamIndex(teams)))
+		return
+	}
 	c.JSON(200, mapObject.Representation())
 }
+
+// FieldUpdates streams conquer events to the client over websocket
+func (h *Handler) FieldUpdates(c *gin.Context) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// upgrader already replied with an error
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	events, err := h.Service.SubscribeFieldUpdates(ctx)
+	if err != nil {
+		conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseInternalServerErr, err.Error()))
+		return
+	}
+
+	// discard client messages, stop when the client goes away
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(30 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -2,34 +2,93 @@ package graphql
 
 import (
 	"context"
+	"errors"
 
 	"github.com/gin-gonic/gin"
+	apihandler "github.com/zodius/api-war/handler"
 	"github.com/zodius/api-war/model"
 	"github.com/zodius/api-war/tools/graph"
 
+	gql "github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
+var Protocol = model.Protocol{
+	Name:        "graphql",
+	ScoreColumn: "graphql",
+	RateLimit:   model.RateLimit{Rate: 20, Burst: 40},
+}
+
+func init() {
+	apihandler.RegisterProtocol(Protocol, RegisterHandler)
+}
+
 func RegisterHandler(service model.Service, app *gin.Engine) {
-	app.POST("/graphql", graphqlHandler(service))
+	graphql := graphqlHandler(service)
+	app.POST("/graphql", graphql)
+	// websocket transport for subscriptions
+	app.GET("/graphql", graphql)
 	app.GET("/graphiql", playgroundHandler())
 }
 
 func graphqlHandler(service model.Service) gin.HandlerFunc {
 	h := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{
-		Service: service,
+		Service:     service,
+		ConquerType: Protocol.Name,
 	}}))
+	h.SetErrorPresenter(errorPresenter)
 	return func(c *gin.Context) {
 		// extract token from header
 		token := c.GetHeader("X-Api-Token")
 		ctx := c.Request.Context()
 		ctx = context.WithValue(ctx, "token", token)
+		ctx = context.WithValue(ctx, "ip", c.ClientIP())
 		request := c.Request.WithContext(ctx)
 		h.ServeHTTP(c.Writer, request)
 	}
 }
 
+// errorPresenter adds extensions.code to errors clients are expected to handle
+func errorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	presented := gql.DefaultErrorPresenter(ctx, err)
+
+	var limited *model.RateLimitError
+	var resync *model.ResyncRequiredError
+	var locked *model.FieldLockedError
+	switch {
+	case errors.As(err, &limited):
+		presented.Extensions = extensions(presented, "RATE_LIMITED")
+		presented.Extensions["retryAfter"] = limited.RetryAfterSeconds()
+	case errors.As(err, &resync):
+		presented.Extensions = extensions(presented, "RESYNC_REQUIRED")
+		presented.Extensions["version"] = resync.Version
+	case errors.As(err, &locked):
+		presented.Extensions = extensions(presented, "FIELD_LOCKED")
+		presented.Extensions["unlockAt"] = locked.UnlockAt
+	case errors.Is(err, model.ErrRoundNotActive):
+		presented.Extensions = extensions(presented, "ROUND_NOT_ACTIVE")
+	case errors.Is(err, model.ErrTeammateField):
+		presented.Extensions = extensions(presented, "TEAMMATE_FIELD")
+	case errors.Is(err, model.ErrBanned):
+		presented.Extensions = extensions(presented, "BANNED")
+	case errors.Is(err, model.ErrUnauthorized):
+		presented.Extensions = extensions(presented, "UNAUTHENTICATED")
+	case errors.Is(err, model.ErrForbidden):
+		presented.Extensions = extensions(presented, "FORBIDDEN")
+	}
+	return presented
+}
+
+func extensions(presented *gqlerror.Error, code string) map[string]interface{} {
+	if presented.Extensions == nil {
+		presented.Extensions = make(map[string]interface{})
+	}
+	presented.Extensions["code"] = code
+	return presented.Extensions
+}
+
 func playgroundHandler() gin.HandlerFunc {
 	h := playground.Handler("GraphQL", "/graphql")
 	return func(c *gin.Context) {
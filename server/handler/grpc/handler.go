@@ -0,0 +1,190 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/zodius/api-war/handler"
+	"github.com/zodius/api-war/model"
+	"github.com/zodius/api-war/tools/rpc"
+
+	grpcgo "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var Protocol = model.Protocol{
+	Name:        "grpc",
+	ScoreColumn: "grpc",
+	RateLimit:   model.RateLimit{Rate: 20, Burst: 40},
+}
+
+func init() {
+	// gRPC has no gin routes, the server is started by NewServer
+	handler.RegisterProtocol(Protocol, nil)
+}
+
+type Handler struct {
+	rpc.UnimplementedBattlefieldServer
+	Service model.Service
+	// Proxies may report the client address in x-forwarded-for
+	Proxies model.TrustedProxies
+}
+
+// NewServer returns a gRPC server with the battlefield service and server reflection registered
+func NewServer(service model.Service, proxies model.TrustedProxies) *grpcgo.Server {
+	handler := &Handler{
+		Service: service,
+		Proxies: proxies,
+	}
+
+	server := grpcgo.NewServer()
+	rpc.RegisterBattlefieldServer(server, handler)
+	reflection.Register(server)
+	return server
+}
+
+func (h *Handler) Login(ctx context.Context, req *rpc.LoginRequest) (*rpc.LoginResponse, error) {
+	token, err := h.Service.Login(req.GetUsername(), req.GetPassword())
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidCredentials) {
+			return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+		}
+		if errors.Is(err, model.ErrBanned) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &rpc.LoginResponse{Token: token}, nil
+}
+
+func (h *Handler) Register(ctx context.Context, req *rpc.RegisterRequest) (*rpc.RegisterResponse, error) {
+	if err := h.Service.Register(req.GetUsername(), req.GetPassword()); err != nil {
+		if errors.Is(err, model.ErrUserExist) {
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &rpc.RegisterResponse{}, nil
+}
+
+func (h *Handler) ConquerField(ctx context.Context, req *rpc.ConquerFieldRequest) (*rpc.ConquerFieldResponse, error) {
+	token, err := tokenFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fieldID := int(req.GetFieldId())
+	if err := h.Service.CheckClientRateLimit(h.clientIP(ctx), Protocol.Name, 1); err != nil {
+		return nil, serviceError(ctx, err)
+	}
+
+	result, err := h.Service.ConquerField(token, fieldID, Protocol.Name)
+	if err != nil {
+		return nil, serviceError(ctx, err)
+	}
+	return &rpc.ConquerFieldResponse{
+		FieldId:         int32(result.FieldID),
+		PreviousOwner:   result.PreviousOwner,
+		NewOwner:        result.NewOwner,
+		PointsAwarded:   int32(result.PointsAwarded),
+		WasAlreadyOwned: result.WasAlreadyOwned,
+		Timestamp:       timestamppb.New(result.Timestamp),
+	}, nil
+}
+
+func (h *Handler) ListMyFields(ctx context.Context, req *rpc.ListMyFieldsRequest) (*rpc.ListMyFieldsResponse, error) {
+	token, err := tokenFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := h.Service.GetUserConquerField(token, Protocol.Name)
+	if err != nil {
+		return nil, serviceError(ctx, err)
+	}
+
+	fieldIDs := make([]int32, 0, len(fields))
+	for _, field := range fields {
+		fieldIDs = append(fieldIDs, int32(field))
+	}
+	return &rpc.ListMyFieldsResponse{FieldIds: fieldIDs}, nil
+}
+
+func (h *Handler) StreamFieldUpdates(req *rpc.StreamFieldUpdatesRequest, stream rpc.Battlefield_StreamFieldUpdatesServer) error {
+	events, err := h.Service.SubscribeFieldUpdates(stream.Context())
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	start, end := int(req.GetStart()), int(req.GetEnd())
+	for event := range events {
+		if start != 0 && event.FieldID < start {
+			continue
+		}
+		if end != 0 && event.FieldID > end {
+			continue
+		}
+		if err := stream.Send(&rpc.FieldUpdate{
+			FieldId:     int32(event.FieldID),
+			ConquerType: event.ConquerType,
+			Owner:       event.Owner,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tokenFromContext extracts the api token from request metadata
+func tokenFromContext(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	tokens := md.Get("x-api-token")
+	if len(tokens) == 0 || tokens[0] == "" {
+		return "", status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	return tokens[0], nil
+}
+
+// clientIP returns the address the call came from, trusted proxies in front
+// of the backends forward it in x-forwarded-for
+func (h *Handler) clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	remote, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		remote = p.Addr.String()
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	return h.Proxies.ClientIP(remote, md.Get("x-forwarded-for"))
+}
+
+// serviceError converts service errors of authenticated calls to gRPC status
+func serviceError(ctx context.Context, err error) error {
+	var limited *model.RateLimitError
+	var locked *model.FieldLockedError
+	switch {
+	case errors.As(err, &limited):
+		grpcgo.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(limited.RetryAfterSeconds())))
+		return status.Error(codes.ResourceExhausted, limited.Error())
+	case errors.As(err, &locked):
+		grpcgo.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(locked.RetryAfterSeconds(time.Now()))))
+		return status.Error(codes.FailedPrecondition, locked.Error())
+	case errors.Is(err, model.ErrRoundNotActive), errors.Is(err, model.ErrTeammateField):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, model.ErrFieldOutOfRange):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, model.ErrNotFound):
+		return status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	return status.Error(codes.Internal, err.Error())
+}
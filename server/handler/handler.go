@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zodius/api-war/model"
+)
+
+// RouteRegistrar mounts the routes of a protocol
+type RouteRegistrar func(service model.Service, app *gin.Engine)
+
+var (
+	registrarLock = new(sync.Mutex)
+	registrars    = make([]RouteRegistrar, 0)
+)
+
+// RegisterProtocol registers a protocol with the model and remembers its
+// routes for RegisterRoutes, protocol packages call it from init
+func RegisterProtocol(protocol model.Protocol, routes RouteRegistrar) model.Protocol {
+	protocol = model.RegisterProtocol(protocol)
+
+	registrarLock.Lock()
+	defer registrarLock.Unlock()
+	if routes != nil {
+		registrars = append(registrars, routes)
+	}
+	return protocol
+}
+
+// RegisterRoutes mounts the routes of every registered protocol
+func RegisterRoutes(service model.Service, app *gin.Engine) {
+	registrarLock.Lock()
+	defer registrarLock.Unlock()
+	for _, routes := range registrars {
+		routes(service, app)
+	}
+}
@@ -3,11 +3,23 @@ package restful
 import (
 	"errors"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/zodius/api-war/handler"
 	"github.com/zodius/api-war/model"
 )
 
+var Protocol = model.Protocol{
+	Name:        "restful",
+	ScoreColumn: "restful",
+	RateLimit:   model.RateLimit{Rate: 20, Burst: 40},
+}
+
+func init() {
+	handler.RegisterProtocol(Protocol, RegisterHandler)
+}
+
 type Handler struct {
 	Service model.Service
 }
@@ -21,6 +33,9 @@ func RegisterHandler(service model.Service, app *gin.Engine) {
 
 	api.POST("/register", handler.Register)
 	api.POST("/login", handler.Login)
+	api.POST("/logout", handler.Logout)
+	api.POST("/logout/all", handler.LogoutAll)
+	api.POST("/conquer", handler.ConquerBatch)
 	api.POST("/conquer/:id", handler.Conquer)
 	api.GET("/fields", handler.GetConquerFields)
 }
@@ -44,17 +59,97 @@ func (h *Handler) Conquer(c *gin.Context) {
 		return
 	}
 
-	if fieldIDInt <= 0 || fieldIDInt > model.FieldCount {
-		c.JSON(400, gin.H{"error": "field id out of range"})
+	if err := h.Service.CheckClientRateLimit(c.ClientIP(), Protocol.Name, 1); err != nil {
+		conquerError(c, err)
 		return
 	}
 
-	if err := h.Service.ConquerField(token, fieldIDInt, model.TypeRestful); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+	result, err := h.Service.ConquerField(token, fieldIDInt, Protocol.Name)
+	if err != nil {
+		conquerError(c, err)
 		return
 	}
 
-	c.JSON(200, gin.H{})
+	c.JSON(200, result)
+}
+
+// ConquerBatch conquers the fields of {"fieldIDs": [...]} in order, fields
+// that weren't conquered have an error in their result
+func (h *Handler) ConquerBatch(c *gin.Context) {
+	token := c.GetHeader("X-Api-Token")
+	if token == "" {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req struct {
+		FieldIDs []int `json:"fieldIDs"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "fieldIDs must be a list of integers"})
+		return
+	}
+
+	// an invalid batch must not use up the limit
+	if err := h.Service.CheckConquerBatch(req.FieldIDs); err != nil {
+		conquerError(c, err)
+		return
+	}
+	if err := h.Service.CheckClientRateLimit(c.ClientIP(), Protocol.Name, len(req.FieldIDs)); err != nil {
+		conquerError(c, err)
+		return
+	}
+
+	items, err := h.Service.ConquerFields(token, req.FieldIDs, Protocol.Name)
+	if err != nil {
+		conquerError(c, err)
+		return
+	}
+
+	results := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		results = append(results, batchResult(item))
+	}
+	c.JSON(200, gin.H{"results": results})
+}
+
+// batchResult is the result of one field of a batch conquer, failed fields
+// get the error of the single conquer response
+func batchResult(item model.ConquerItem) interface{} {
+	if item.Err == nil {
+		return item.ConquerResult
+	}
+	result := gin.H{"fieldID": item.FieldID, "error": item.Err.Error()}
+	var locked *model.FieldLockedError
+	if errors.As(item.Err, &locked) {
+		result["error"] = "field locked"
+		result["unlockAt"] = locked.UnlockAt
+	}
+	return result
+}
+
+// conquerError writes the response for errors of conquer calls
+func conquerError(c *gin.Context, err error) {
+	var limited *model.RateLimitError
+	var locked *model.FieldLockedError
+	switch {
+	case errors.As(err, &limited):
+		c.Header("Retry-After", strconv.Itoa(limited.RetryAfterSeconds()))
+		c.JSON(429, gin.H{"error": "rate limited", "retryAfter": limited.RetryAfterSeconds()})
+	case errors.As(err, &locked):
+		c.Header("Retry-After", strconv.Itoa(locked.RetryAfterSeconds(time.Now())))
+		c.JSON(409, gin.H{"error": "field locked", "unlockAt": locked.UnlockAt})
+	case errors.Is(err, model.ErrRoundNotActive):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrTeammateField):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrFieldOutOfRange), errors.Is(err, model.ErrInvalidBatch):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrNotFound):
+		c.JSON(401, gin.H{"error": "unauthorized"})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
 }
 
 func (h *Handler) GetConquerFields(c *gin.Context) {
@@ -64,7 +159,7 @@ func (h *Handler) GetConquerFields(c *gin.Context) {
 		return
 	}
 
-	fields, err := h.Service.GetUserConquerField(token, model.TypeRestful)
+	fields, err := h.Service.GetUserConquerField(token, Protocol.Name)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -112,6 +207,8 @@ func (h *Handler) Login(c *gin.Context) {
 	if err != nil {
 		if errors.Is(err, model.ErrInvalidCredentials) {
 			c.JSON(401, gin.H{"error": "invalid credentials"})
+		} else if errors.Is(err, model.ErrBanned) {
+			c.JSON(403, gin.H{"error": err.Error()})
 		} else {
 			c.JSON(500, gin.H{"error": err.Error()})
 		}
@@ -134,3 +231,39 @@ func (h *Handler) GetUserList(c *gin.Context) {
 	}
 	c.JSON(200, gin.H{"userList": userList})
 }
+
+func (h *Handler) Logout(c *gin.Context) {
+	token := c.GetHeader("X-Api-Token")
+	if token == "" {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := h.Service.Logout(token); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			c.JSON(401, gin.H{"error": "unauthorized"})
+		} else {
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(200, gin.H{})
+}
+
+func (h *Handler) LogoutAll(c *gin.Context) {
+	token := c.GetHeader("X-Api-Token")
+	if token == "" {
+		c.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := h.Service.LogoutAll(token); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			c.JSON(401, gin.H{"error": "unauthorized"})
+		} else {
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(200, gin.H{})
+}
@@ -0,0 +1,72 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zodius/api-war/model"
+)
+
+// gcraScript implements the generic cell rate algorithm, the key holds the
+// theoretical arrival time (TAT) of the next request in microseconds
+//
+//	KEYS: ratelimit:<key>
+//	ARGV: emission interval in µs, burst, cost, now in µs
+//
+// returns 0 if allowed, otherwise the µs to wait
+var gcraScript = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local newTat = tat + interval * cost
+local allowAt = newTat - interval * burst
+if allowAt > now then
+	return math.ceil(allowAt - now)
+end
+redis.call('SET', KEYS[1], string.format('%d', math.ceil(newTat)), 'PX', math.ceil((newTat - now) / 1000) + 1)
+return 0
+`)
+
+type redisLimiter struct {
+	client *redis.Client
+}
+
+// NewRedisLimiter returns a limiter whose buckets live in redis, so every
+// backend shares them
+func NewRedisLimiter(client *redis.Client) model.RateLimiter {
+	return &redisLimiter{
+		client: client,
+	}
+}
+
+func (l *redisLimiter) Allow(key string, limit model.RateLimit, cost int) (bool, time.Duration, error) {
+	if !limit.Enabled() {
+		return true, 0, nil
+	}
+
+	wait, err := gcraScript.Run(context.Background(), l.client,
+		[]string{fmt.Sprintf("ratelimit:%s", key)},
+		emissionInterval(limit), limit.Burst, cost, time.Now().UnixMicro(),
+	).Int64()
+	if err != nil {
+		return false, 0, err
+	}
+	if wait > 0 {
+		return false, time.Duration(wait) * time.Microsecond, nil
+	}
+	return true, 0, nil
+}
+
+// emissionInterval is the time in µs one request takes to refill
+func emissionInterval(limit model.RateLimit) int64 {
+	return int64(math.Ceil(1e6 / limit.Rate))
+}
@@ -0,0 +1,113 @@
+package limiter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/zodius/api-war/model"
+)
+
+func testLimiter(t *testing.T, limiter model.RateLimiter) {
+	limit := model.RateLimit{Rate: 1, Burst: 3}
+
+	for i := 0; i < limit.Burst; i++ {
+		allowed, _, err := limiter.Allow("alice", limit, 1)
+		if err != nil {
+			t.Fatalf("Allow: %v", err)
+		}
+		if !allowed {
+			t.Fatalf("request %d within burst denied", i)
+		}
+	}
+
+	allowed, retryAfter, err := limiter.Allow("alice", limit, 1)
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if allowed {
+		t.Fatal("request over burst allowed")
+	}
+	if retryAfter <= 0 || retryAfter > time.Second {
+		t.Errorf("retryAfter = %s, want up to one emission interval", retryAfter)
+	}
+
+	// buckets are per key
+	if allowed, _, _ := limiter.Allow("bob", limit, limit.Burst); !allowed {
+		t.Error("full burst of another key denied")
+	}
+	if allowed, _, _ := limiter.Allow("carol", limit, limit.Burst+1); allowed {
+		t.Error("cost over burst allowed")
+	}
+
+	if allowed, _, _ := limiter.Allow("alice", model.RateLimit{}, 100); !allowed {
+		t.Error("disabled limit denied a request")
+	}
+}
+
+func TestRedisLimiter(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{
+		Addr: server.Addr(),
+	})
+	defer client.Close()
+
+	testLimiter(t, NewRedisLimiter(client))
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	testLimiter(t, NewMemoryLimiter())
+}
+
+func TestMemoryLimiterRefill(t *testing.T) {
+	now := time.Now()
+	limiter := &memoryLimiter{
+		lock: NewMemoryLimiter().(*memoryLimiter).lock,
+		now:  func() time.Time { return now },
+		tat:  make(map[string]int64),
+	}
+	limit := model.RateLimit{Rate: 2, Burst: 1}
+
+	if allowed, _, _ := limiter.Allow("alice", limit, 1); !allowed {
+		t.Fatal("first request denied")
+	}
+	if allowed, _, _ := limiter.Allow("alice", limit, 1); allowed {
+		t.Fatal("second request allowed before refill")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if allowed, _, _ := limiter.Allow("alice", limit, 1); !allowed {
+		t.Error("request denied after refill")
+	}
+}
+
+func TestMemoryLimiterSweep(t *testing.T) {
+	now := time.Now()
+	limiter := NewMemoryLimiter().(*memoryLimiter)
+	limiter.now = func() time.Time { return now }
+	limit := model.RateLimit{Rate: 1, Burst: 1}
+
+	for _, key := range []string{"1.2.3.4", "5.6.7.8"} {
+		if allowed, _, _ := limiter.Allow(key, limit, 1); !allowed {
+			t.Fatalf("first request of %s denied", key)
+		}
+	}
+	now = now.Add(sweepInterval + time.Second)
+	if allowed, _, _ := limiter.Allow("9.9.9.9", limit, 1); !allowed {
+		t.Fatal("request denied")
+	}
+	if len(limiter.tat) != 1 {
+		t.Errorf("buckets after sweep = %v, want only the new one", limiter.tat)
+	}
+}
+
+func TestRateLimitError(t *testing.T) {
+	err := error(&model.RateLimitError{RetryAfter: 1500 * time.Millisecond})
+	if !errors.Is(err, model.ErrRateLimited) {
+		t.Error("RateLimitError doesn't match ErrRateLimited")
+	}
+	if seconds := err.(*model.RateLimitError).RetryAfterSeconds(); seconds != 2 {
+		t.Errorf("RetryAfterSeconds = %d, want 2", seconds)
+	}
+}
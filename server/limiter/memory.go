@@ -0,0 +1,70 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/zodius/api-war/model"
+)
+
+// sweepInterval is how often buckets that refilled completely are dropped,
+// like the expiring keys of the redis limiter
+const sweepInterval = time.Minute
+
+type memoryLimiter struct {
+	lock *sync.Mutex
+	now  func() time.Time
+	// key -> theoretical arrival time in µs
+	tat map[string]int64
+	// µs of the last sweep
+	sweptAt int64
+}
+
+// NewMemoryLimiter returns a limiter for a single instance, using the same
+// algorithm as the redis limiter
+func NewMemoryLimiter() model.RateLimiter {
+	return &memoryLimiter{
+		lock: new(sync.Mutex),
+		now:  time.Now,
+		tat:  make(map[string]int64),
+	}
+}
+
+func (l *memoryLimiter) Allow(key string, limit model.RateLimit, cost int) (bool, time.Duration, error) {
+	if !limit.Enabled() {
+		return true, 0, nil
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now().UnixMicro()
+	l.sweep(now)
+	interval := emissionInterval(limit)
+
+	tat, ok := l.tat[key]
+	if !ok || tat < now {
+		tat = now
+	}
+	newTat := tat + interval*int64(cost)
+	allowAt := newTat - interval*int64(limit.Burst)
+	if allowAt > now {
+		return false, time.Duration(allowAt-now) * time.Microsecond, nil
+	}
+	l.tat[key] = newTat
+	return true, 0, nil
+}
+
+// sweep drops the buckets whose arrival time passed, they behave the same as
+// missing ones, caller must hold the lock
+func (l *memoryLimiter) sweep(now int64) {
+	if now-l.sweptAt < sweepInterval.Microseconds() {
+		return
+	}
+	l.sweptAt = now
+	for key, tat := range l.tat {
+		if tat < now {
+			delete(l.tat, key)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrBanned       = errors.New("user is banned")
+)
+
+const (
+	RolePlayer = "player"
+	RoleAdmin  = "admin"
+)
+
+// UserStats is a user as seen by admins, ConquerHistoryCount is keyed by
+// Protocol.ScoreColumn like Score
+type UserStats struct {
+	ID                  int            `json:"id"`
+	Username            string         `json:"username"`
+	Role                string         `json:"role"`
+	Banned              bool           `json:"banned"`
+	ConquerFieldCount   int            `json:"conquerFieldCount"`
+	ConquerHistoryCount map[string]int `json:"conquerHistoryCount"`
+}
+
+// AuditEntry records an admin action
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Admin  string    `json:"admin"`
+	Action string    `json:"action"`
+	Target string    `json:"target"`
+	Detail string    `json:"detail,omitempty"`
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MapChangeLimit is how many field changes are kept for GetMapChanges, older
+// cursors have to resync from a snapshot
+const MapChangeLimit = 10000
+
+var ErrResyncRequired = errors.New("resync required")
+
+// ResyncRequiredError is returned for a cursor whose changes were dropped,
+// errors.Is(err, ErrResyncRequired) matches it
+type ResyncRequiredError struct {
+	// Version is the current map version, a snapshot is at least this new
+	Version uint64
+}
+
+func (e *ResyncRequiredError) Error() string {
+	return fmt.Sprintf("resync required, map is at version %d", e.Version)
+}
+
+func (e *ResyncRequiredError) Is(target error) bool {
+	return target == ErrResyncRequired
+}
+
+// MapChange is a new owner of a field, an empty Owner frees the field
+type MapChange struct {
+	Version     uint64 `json:"version"`
+	FieldID     int    `json:"fieldID"`
+	ConquerType string `json:"conquerType"`
+	Owner       string `json:"owner"`
+}
+
+// MapChanges are the changes after a cursor in version order, applying them
+// brings a map at the cursor to Version
+type MapChanges struct {
+	Version uint64      `json:"version"`
+	Changes []MapChange `json:"changes"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// FieldHistoryLimit is how many ownership changes are kept per field and
+// conquer type
+const FieldHistoryLimit = 100
+
+// FieldChange is an entry of the ownership history of a field, an empty
+// Owner frees the field
+type FieldChange struct {
+	Time        time.Time
+	ConquerType string
+	Owner       string
+}
+
+// FieldHolding is a period a user held a field
+type FieldHolding struct {
+	Owner       string    `json:"owner"`
+	ConquerType string    `json:"conquerType"`
+	StartAt     time.Time `json:"startAt"`
+	HeldSeconds float64   `json:"heldSeconds"`
+	// Current is true while the owner still holds the field
+	Current bool `json:"current"`
+}
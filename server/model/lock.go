@@ -0,0 +1,51 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var ErrFieldLocked = errors.New("field locked")
+
+// FieldLockedError is returned when conquering a field during its lock,
+// errors.Is(err, ErrFieldLocked) matches it
+type FieldLockedError struct {
+	UnlockAt time.Time
+}
+
+func (e *FieldLockedError) Error() string {
+	return fmt.Sprintf("field locked until %s", e.UnlockAt.UTC().Format(time.RFC3339Nano))
+}
+
+// RetryAfterSeconds rounds the time until UnlockAt up to whole seconds for
+// Retry-After headers
+func (e *FieldLockedError) RetryAfterSeconds(now time.Time) int {
+	seconds := int(math.Ceil(e.UnlockAt.Sub(now).Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+func (e *FieldLockedError) Is(target error) bool {
+	return target == ErrFieldLocked
+}
+
+// FieldLock is how long a field can't be conquered after a conquer, a zero
+// Duration disables the lock
+type FieldLock struct {
+	Duration time.Duration `yaml:"duration"`
+	// FirstCapture replaces Duration after a conquer of a free field, zero
+	// uses Duration
+	FirstCapture time.Duration `yaml:"firstCapture"`
+}
+
+// For returns the lock after a conquer, previousOwner is empty for free fields
+func (l FieldLock) For(previousOwner string) time.Duration {
+	if previousOwner == "" && l.FirstCapture > 0 {
+		return l.FirstCapture
+	}
+	return l.Duration
+}
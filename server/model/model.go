@@ -1,47 +1,127 @@
 package model
 
 import (
+	"context"
 	"errors"
+	"time"
 )
 
 var (
 	ErrNotFound           = errors.New("not found")
 	ErrUserExist          = errors.New("user already exists")
 	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrUnknownProtocol    = errors.New("unknown conquer type")
+	ErrFieldOutOfRange    = errors.New("field id out of range")
+	ErrInvalidRange       = errors.New("invalid field range")
+	ErrInvalidPage        = errors.New("invalid page")
+	ErrInvalidBatch       = errors.New("invalid batch")
 )
 
 const (
-	FieldCount  = 1000000
-	BatchSize   = 1000
-	TypeRestful = "restful"
-	TypeGraphql = "graphql"
+	DefaultFieldCount = 1000000
+	DefaultBatchSize  = 1000
+	// DefaultConquerBatchSize matches the burst of the default conquer rate
+	// limits, larger batches would never be allowed
+	DefaultConquerBatchSize = 40
 )
 
+const (
+	DefaultScoreboardLimit = 100
+	MaxScoreboardLimit     = 1000
+	// MaxNeighbours bounds the scores shown on each side of the caller
+	MaxNeighbours = 50
+)
+
+// MapConfig sizes the map, field ids run from 1 to FieldCount
+type MapConfig struct {
+	FieldCount int `yaml:"fieldCount"`
+	// BatchSize is how many fields the redis repo reads per request
+	BatchSize int `yaml:"batchSize"`
+	// Lock protects fields from being retaken right after a conquer
+	Lock FieldLock `yaml:"lock"`
+	// ConquerBatchSize is the most fields a batch conquer can take
+	ConquerBatchSize int `yaml:"conquerBatchSize"`
+}
+
 /*
 	Redis schema:
 	- Hashmap:
-		{"user:<username>" : {"password":<password>}, "id":<id>} }
+		{"user:<username>" : {"password":<password hash>}, "id":<id>, "role":<role>, "banned":<0|1>, "team":<team id>} }
+		{"team:<id>": {"name":<name>}}
+		{"teams:name": {<name>:<team id>}}
 		{"fields:<type>:conquerer": {<fieldID>:<owner>}}
+		{"user:<username>:tokens": {"<token>:created":<unix ms>, "<token>:lastUsed":<unix ms>}} (expires with the newest token)
+		{"round": {"id":<id>, "startAt":<unix ms>, "endAt":<unix ms>, "freezeAt":<unix ms, 0 without freeze>, "scoring":<Scoring>}}
 	- Key:
-		{"token:<token>" : <username>}
+		{"token:<token>" : <username>} (expires 15 minutes after last use)
+		{"ratelimit:<type>:user:<username>": <theoretical arrival time µs>}
+		{"ratelimit:<type>:ip:<ip>": <theoretical arrival time µs>}
 		{"usercount": int}
+		{"teamcount": int}
+		{"score:rank:seq": int} (incremented by every change of a field count)
+		{"map:version": int} (incremented by every change of a field owner)
+		{"field:<type>:<fieldID>:lock": <unlock time unix ms>:<archived rounds when locked>} (expires at the unlock time, ends with its round)
+		{"map:changes": zset} (<version>:<type>:<fieldID>:<owner> -> version, the last MapChangeLimit changes)
+		{"map:changes:floor": int} (oldest version GetMapChanges can start from)
+		{"events:conquer": stream} (every change of a field owner, see repo.Replay)
+		{"frozen:round": <id of the round the frozen scoreboard belongs to>}
 	- ZSet:
 		{"users": [<username> <id>]}
-		{"score:conquerCount": [<username> <count>]}
-		{"score:conquerHistory:restful": [<username> <count>]}
-		{"score:conquerHistory:graphql": [<username> <count>]}
+		{"rounds:archivedAt": [<round id> <unix ms>]}
+		{"user:<username>:tokens:expiry": [<token> <expiry unix ms>]} (expired tokens are pruned from the index on every login and use)
+		{"score:conquerCount": [<username> <currently held field count>]}
+		{"score:conquerHistory:<type>": [<username> <count>]}
+		{"score:rank": [<username> <count * 2^32 + 2^32-1 - score:rank:seq at the last count change>]}
+			(scoreboard order, ties go to whoever reached the count first)
+		{"score:holdTime": [<username> <ms fields were held until score:holdTime:since>]}
+		{"score:holdTime:since": [<username> <unix ms of the last count change>]}
+			(held time is settled lazily on count changes, see settleHoldLua)
+		{"frozen:score:conquerCount"}, {"frozen:score:conquerHistory:<type>"}, {"frozen:score:rank"},
+		{"frozen:score:holdTime"}
+			(scoreboard copy taken at freeze time)
+	- List:
+		{"audit": [AuditEntry json, newest first]}
+		{"team:<id>:members": [<username>, in join order]}
+		{"field:<fieldID>:history": [<unix ms>:<type>:<owner>, newest first, the last FieldHistoryLimit changes of every type]}
+	- Set:
+		{"rounds:archived": [<round id>]}
+		{"team:<id>:invites": [<username>]}
+	- Archive (keys renamed at round end):
+		{"archive:round:<id>:round"}, {"archive:round:<id>:fields:<type>:conquerer"},
+		{"archive:round:<id>:score:conquerCount"}, {"archive:round:<id>:score:conquerHistory:<type>"},
+		{"archive:round:<id>:score:rank"}, {"archive:round:<id>:score:holdTime"}
 	- Bitmap:
 		{"user:<username>:conquerField:<type>": <fieldID>}
+	- PubSub:
+		{"channel:conquer": ConquerEvent}
 */
 
 type User struct {
 	ID       int    `json:"id"`
 	Username string `json:"username"`
 	Password string `json:"-"`
+	// Role is RolePlayer or RoleAdmin, empty for players
+	Role   string `json:"-"`
+	Banned bool   `json:"-"`
+	// TeamID is 0 for users without a team
+	TeamID int `json:"teamID"`
+}
+
+func (u User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+type Session struct {
+	// ID identifies the session without revealing the token
+	ID         string    `json:"id"`
+	Token      string    `json:"-"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	Current    bool      `json:"current"`
 }
 
 type Owner struct {
-	ConquerType string `json:"conquerType"` // restful, grqphql
+	ConquerType string `json:"conquerType"` // registered protocol name
 	Owner       string `json:"owner"`
 }
 
@@ -68,10 +148,51 @@ func (m *Map) Representation() interface{} {
 	return represent
 }
 
+// Score is a scoreboard entry, Rank starts at 1 and is unique
 type Score struct {
-	Username            string         `json:"username"`
-	ConquerFieldCount   int            `json:"conquerFieldCount"`
+	Rank              int    `json:"rank"`
+	Username          string `json:"username"`
+	ConquerFieldCount int    `json:"conquerFieldCount"`
+	// ConquerHistoryCount is keyed by Protocol.ScoreColumn
 	ConquerHistoryCount map[string]int `json:"conquerHistoryCount"`
+	// HoldPoints is a point for every second a field was held in the round
+	HoldPoints int `json:"holdPoints"`
+}
+
+// ScoreNeighbourhood is a user's score and the scores ranked around it
+type ScoreNeighbourhood struct {
+	Score Score `json:"score"`
+	// ScoreList is in rank order and includes Score
+	ScoreList []Score `json:"scoreList"`
+}
+
+type ConquerResult struct {
+	FieldID         int       `json:"fieldID"`
+	PreviousOwner   string    `json:"previousOwner"`
+	NewOwner        string    `json:"newOwner"`
+	PointsAwarded   int       `json:"pointsAwarded"`
+	WasAlreadyOwned bool      `json:"wasAlreadyOwned"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// ConquerOutcome is the result of one field of Repo.ConquerFields, Err is
+// ErrTeammateField or a FieldLockedError if the field wasn't conquered
+type ConquerOutcome struct {
+	PreviousOwner string
+	Err           error
+}
+
+// ConquerItem is the result of one field of Service.ConquerFields, Err is set
+// if the field wasn't conquered
+type ConquerItem struct {
+	ConquerResult
+	Err error `json:"-"`
+}
+
+type ConquerEvent struct {
+	FieldID     int    `json:"fieldID"`
+	ConquerType string `json:"conquerType"`
+	Owner       string `json:"owner"`
 }
 
 type Service interface {
@@ -79,25 +200,188 @@ type Service interface {
 	Login(username, password string) (token string, err error)
 	Register(username, password string) error
 	GetMe(token string) (username string, err error)
+	Logout(token string) error
+	// LogoutAll revokes every token of the token owner
+	LogoutAll(token string) error
+	GetSessions(token string) ([]Session, error)
 	// basic information
+	// GetCurrentMap returns fields start to end, ErrInvalidRange for ranges
+	// outside the configured map
 	GetCurrentMap(start, end int) (Map Map, err error)
+	// GetWholeMap returns every field of the configured map
+	GetWholeMap() (Map Map, err error)
+	// GetFields returns the given fields in one read, ErrFieldOutOfRange if
+	// one is outside the configured map
+	GetFields(fieldIDs []int) (Map Map, err error)
+	// GetFieldCount returns the configured map size
+	GetFieldCount() int
+	// GetMapSnapshot returns the binary encoded MapSnapshot and its version
+	GetMapSnapshot() (snapshot []byte, version uint64, err error)
+	GetMapChanges(since uint64) (MapChanges, error)
 	GetUserList(token string) (userList []User, err error) // this is used to get username by id for each client
 	// services for exploit
 	GetUserConquerField(token string, conquerType string) ([]int, error)
-	ConquerField(token string, fieldID int, conquerType string) error
-	// scoreboard
-	GetScoreboard() (scoreList []Score, err error)
+	// GetFieldHistory returns who held a field and for how long, newest
+	// first, a holding ends with the next change or the end of its round
+	GetFieldHistory(fieldID int) ([]FieldHolding, error)
+	// ConquerField is rate limited per user and conquer type, returns
+	// ErrFieldOutOfRange for ids outside the configured map and a
+	// FieldLockedError while the field is locked
+	ConquerField(token string, fieldID int, conquerType string) (ConquerResult, error)
+	// ConquerFields conquers up to MapConfig.ConquerBatchSize fields, every
+	// field costs one request of the rate limit. Field errors are reported
+	// per item, ErrInvalidBatch for empty or oversized batches.
+	ConquerFields(token string, fieldIDs []int, conquerType string) ([]ConquerItem, error)
+	// CheckConquerBatch returns ErrInvalidBatch for batches ConquerFields
+	// rejects, handlers call it before charging CheckClientRateLimit
+	CheckConquerBatch(fieldIDs []int) error
+	// CheckClientRateLimit takes cost requests from the per address limit of
+	// a conquer type, handlers call it before ConquerField and ConquerFields.
+	// Field ids outside the map still cost, malformed batches must be
+	// rejected with CheckConquerBatch first.
+	CheckClientRateLimit(ip string, conquerType string, cost int) error
+	// live updates, the channel is closed when ctx is done
+	SubscribeFieldUpdates(ctx context.Context) (<-chan ConquerEvent, error)
+	// scoreboard, during the freeze window of a round this is the frozen copy.
+	// A zero limit is DefaultScoreboardLimit, ErrInvalidPage for negative
+	// values or a limit above MaxScoreboardLimit
+	GetScoreboard(offset, limit int) (scoreList []Score, err error)
+	// GetMyScore returns the caller's score with up to around scores on
+	// each side, ErrNotFound if the caller has no score
+	GetMyScore(token string, around int) (ScoreNeighbourhood, error)
+	// teams, a user is in at most one team and joins on invite
+	CreateTeam(token, name string) (Team, error)
+	// InviteToTeam is allowed for members of the team
+	InviteToTeam(token string, teamID int, username string) error
+	JoinTeam(token string, teamID int) (Team, error)
+	GetTeam(teamID int) (Team, error)
+	// GetTeams lists every team in id order
+	GetTeams() ([]Team, error)
+	// GetTeamScoreboard ranks teams by the summed held field count of their
+	// members, pages like GetScoreboard
+	GetTeamScoreboard(offset, limit int) ([]TeamScore, error)
+	// rounds, without a configured round the game is always running
+	GetRound() (RoundStatus, error)
+	// SetRound reschedules the current round, or starts a new one after the
+	// current round has ended
+	SetRound(round Round) (Round, error)
+	// GetRoundScoreboard returns the final scoreboard of an archived round
+	GetRoundScoreboard(roundID, offset, limit int) (scoreList []Score, err error)
+	// WatchRounds freezes and archives rounds on time until ctx is done
+	WatchRounds(ctx context.Context)
+	// BootstrapAdmin makes username an admin, creating it with password if
+	// it doesn't exist
+	BootstrapAdmin(username, password string) error
+	// admin, token must belong to an admin, every action is audited
+	AdminListUsers(token string) ([]UserStats, error)
+	AdminGetUserSessions(token, username string) ([]Session, error)
+	// AdminBanUser revokes every token of the user and blocks login
+	AdminBanUser(token, username string) error
+	AdminUnbanUser(token, username string) error
+	// AdminSetFieldOwner gives a field to username, empty username frees it
+	AdminSetFieldOwner(token string, fieldID int, conquerType, username string) error
+	// AdminWipeUser frees every field the user holds
+	AdminWipeUser(token, username string) error
+	AdminGetRound(token string) (RoundStatus, error)
+	AdminSetRound(token string, round Round) (Round, error)
+	AdminGetAuditLog(token string, limit int) ([]AuditEntry, error)
 }
 
 type Repo interface {
 	GetUser(username string) (User, error)
+	// password is stored as given, hashing is up to the service
 	CreateUser(username, password string) error
+	SetPassword(username, password string) error
+	SetRole(username, role string) error
+	SetBanned(username string, banned bool) error
+	// GetUserStats lists every user in id order
+	GetUserStats() ([]UserStats, error)
 	CreateToken(username string) (token string, err error)
+	// GetTokenUsername also extends the token lifetime, tokens expire
+	// after 15 minutes without use
 	GetTokenUsername(token string) (username string, err error)
+	DeleteToken(token string) error
+	DeleteUserTokens(username string) error
+	// GetUserTokens lists the unexpired tokens of a user
+	GetUserTokens(username string) ([]Session, error)
 	GetMap(start, end int) (Map, error)
+	// GetFields reads the owners of the given fields like GetMap, in the
+	// order of fieldIDs
+	GetFields(fieldIDs []int) (Map, error)
+	GetMapVersion() (uint64, error)
+	// GetMapSnapshot reads the owners of fields 1 to fieldCount
+	GetMapSnapshot(fieldCount int) (MapSnapshot, error)
+	// GetMapChanges returns the changes after version since, or a
+	// ResyncRequiredError if some of them were dropped
+	GetMapChanges(since uint64) (MapChanges, error)
 	GetUserList() (userList []User, err error)
 	GetUserConquerField(username string, conquerType string) ([]int, error)
-	GetScoreboard() (scoreList []Score, err error)
-	SetFieldConquerer(fieldID int, conquerType, username string) error
-	AddScore(username string, fieldID int, conquerType string) error
+	// GetFieldHistory returns the ownership changes of a field over every
+	// conquer type, newest first
+	GetFieldHistory(fieldID int) ([]FieldChange, error)
+	// GetArchiveTimes returns when rounds were archived, oldest first
+	GetArchiveTimes() ([]time.Time, error)
+	// GetScoreboard returns limit scores in rank order starting at offset
+	GetScoreboard(offset, limit int) (scoreList []Score, err error)
+	// GetScoreNeighbourhood returns the scores from around ranks above to
+	// around ranks below a user out of one ranking, ErrNotFound without a score
+	GetScoreNeighbourhood(username string, around int) (scoreList []Score, err error)
+	// Conquer atomically sets the field owner, the owner bitmap and scores,
+	// returns the previous owner or empty string if the field was free.
+	// Conquering a field the user already owns changes nothing, a field held
+	// by a teammate returns ErrTeammateField. After the conquer the field is
+	// locked for lock.For(previousOwner), conquering a locked field returns a
+	// FieldLockedError.
+	Conquer(fieldID int, conquerType, username string, lock FieldLock) (previousOwner string, err error)
+	// ConquerFields conquers the fields in order like Conquer in one round
+	// trip, the outcomes are in the order of fieldIDs
+	ConquerFields(fieldIDs []int, conquerType, username string, lock FieldLock) ([]ConquerOutcome, error)
+	// SetFieldOwner moves a field like Conquer without counting a conquer,
+	// empty username frees the field
+	SetFieldOwner(fieldID int, conquerType, username string) (previousOwner string, err error)
+	// WipeUserFields frees every field of the user, returns a change with an
+	// empty owner for every freed field
+	WipeUserFields(username string) (freed []MapChange, err error)
+	// CreateTeam makes username the first member of a new team, returns
+	// ErrTeamExist for a taken name and ErrAlreadyInTeam
+	CreateTeam(name, username string) (Team, error)
+	// GetTeam returns ErrNotFound for unknown teams
+	GetTeam(teamID int) (Team, error)
+	// GetTeams lists every team in id order
+	GetTeams() ([]Team, error)
+	// InviteToTeam returns ErrNotFound for unknown teams or users
+	InviteToTeam(teamID int, username string) error
+	// JoinTeam returns ErrNotInvited, ErrAlreadyInTeam or ErrTeamFull
+	JoinTeam(teamID int, username string) error
+	// GetTeamScores sums the scores of every team in id order, unranked
+	GetTeamScores() ([]TeamScore, error)
+	// GetFrozenTeamScores sums the frozen scores with the current members,
+	// ErrNotFound until the scoreboard of the round is frozen
+	GetFrozenTeamScores(roundID int) ([]TeamScore, error)
+	// GetRound returns ErrNotFound if no round is configured
+	GetRound() (Round, error)
+	SetRound(round Round) error
+	// FreezeScoreboard copies the scoreboard once per round, later calls for
+	// the same round do nothing
+	FreezeScoreboard(roundID int) error
+	// GetFrozenScoreboard returns ErrNotFound until the scoreboard of the
+	// round is frozen
+	GetFrozenScoreboard(roundID, offset, limit int) (scoreList []Score, err error)
+	GetFrozenScoreNeighbourhood(roundID int, username string, around int) (scoreList []Score, err error)
+	// ArchiveRound moves the map and scoreboard of a round to its archive and
+	// resets them for the next round, once per round
+	ArchiveRound(roundID int) error
+	// GetArchivedScoreboard returns ErrNotFound for rounds not archived
+	GetArchivedScoreboard(roundID, offset, limit int) (scoreList []Score, err error)
+	AddAuditEntry(entry AuditEntry) error
+	// GetAuditLog returns up to limit entries, newest first
+	GetAuditLog(limit int) ([]AuditEntry, error)
+}
+
+// Broker fans out conquer events to every backend instance
+type Broker interface {
+	Publish(event ConquerEvent) error
+	// Subscribe returns a channel of events published after the call,
+	// the channel is closed when ctx is done
+	Subscribe(ctx context.Context) (<-chan ConquerEvent, error)
 }
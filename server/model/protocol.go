@@ -0,0 +1,85 @@
+package model
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Protocol is an API surface players conquer fields through, every protocol
+// has its own ownership layer, bitmaps and conquer history score
+type Protocol struct {
+	// Name is the conquerType, used in redis keys and the map representation
+	Name string
+	// ScoreColumn is the key of the protocol in Score.ConquerHistoryCount
+	ScoreColumn string
+	// RateLimit applies to the conquers of each user
+	RateLimit RateLimit
+	// IPRateLimit applies to the conquers from each client address, disabled by default
+	IPRateLimit RateLimit
+}
+
+var (
+	protocolLock = new(sync.RWMutex)
+	protocols    = make([]Protocol, 0)
+)
+
+// RegisterProtocol makes a protocol known to repo, map and scoreboard,
+// it panics if the same name is registered twice
+func RegisterProtocol(protocol Protocol) Protocol {
+	protocolLock.Lock()
+	defer protocolLock.Unlock()
+
+	if protocol.Name == "" {
+		panic("model: protocol name is required")
+	}
+	if protocol.ScoreColumn == "" {
+		protocol.ScoreColumn = protocol.Name
+	}
+	for _, registered := range protocols {
+		if registered.Name == protocol.Name {
+			panic(fmt.Sprintf("model: protocol %q registered twice", protocol.Name))
+		}
+	}
+	protocols = append(protocols, protocol)
+	return protocol
+}
+
+// Protocols returns all registered protocols in registration order
+func Protocols() []Protocol {
+	protocolLock.RLock()
+	defer protocolLock.RUnlock()
+
+	result := make([]Protocol, len(protocols))
+	copy(result, protocols)
+	return result
+}
+
+// GetProtocol looks up a registered protocol by conquerType
+func GetProtocol(name string) (Protocol, bool) {
+	protocolLock.RLock()
+	defer protocolLock.RUnlock()
+
+	for _, protocol := range protocols {
+		if protocol.Name == name {
+			return protocol, true
+		}
+	}
+	return Protocol{}, false
+}
+
+// UpdateProtocol replaces the settings of a registered protocol
+func UpdateProtocol(protocol Protocol) error {
+	protocolLock.Lock()
+	defer protocolLock.Unlock()
+
+	for i, registered := range protocols {
+		if registered.Name == protocol.Name {
+			if protocol.ScoreColumn == "" {
+				protocol.ScoreColumn = registered.ScoreColumn
+			}
+			protocols[i] = protocol
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownProtocol, protocol.Name)
+}
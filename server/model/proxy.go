@@ -0,0 +1,73 @@
+package model
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// TrustedProxies are the addresses allowed to report the client address in
+// X-Forwarded-For, gin is given the same list with SetTrustedProxies
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies reads IP addresses and CIDR ranges
+func ParseTrustedProxies(proxies []string) (TrustedProxies, error) {
+	trusted := make(TrustedProxies, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			proxy = fmt.Sprintf("%s/%d", proxy, bits)
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+		}
+		trusted = append(trusted, network)
+	}
+	return trusted, nil
+}
+
+func (t TrustedProxies) trusts(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range t {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the client address of a request from remote with the
+// X-Forwarded-For values forwarded. Like gin, hops are read from the right
+// while they are trusted proxies, so addresses a client sends itself are
+// never used.
+func (t TrustedProxies) ClientIP(remote string, forwarded []string) string {
+	if !t.trusts(remote) {
+		return remote
+	}
+	var hops []string
+	for _, value := range forwarded {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		if !t.trusts(hop) {
+			return hop
+		}
+		remote = hop
+	}
+	return remote
+}
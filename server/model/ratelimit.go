@@ -0,0 +1,89 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimitError is returned when a caller exceeds a rate limit,
+// errors.Is(err, ErrRateLimited) matches it
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited, retry after %s", e.RetryAfter)
+}
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds for Retry-After headers
+func (e *RateLimitError) RetryAfterSeconds() int {
+	seconds := int(math.Ceil(e.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// RateLimit allows Burst requests at once, refilled at Rate requests per
+// second, a zero Rate disables the limit
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l RateLimit) Enabled() bool {
+	return l.Rate > 0
+}
+
+// String formats the limit as "<rate>:<burst>", the format ParseRateLimit reads
+func (l RateLimit) String() string {
+	if !l.Enabled() {
+		return "0"
+	}
+	return fmt.Sprintf("%s:%d", strconv.FormatFloat(l.Rate, 'f', -1, 64), l.Burst)
+}
+
+// ParseRateLimit reads "<rate>:<burst>" or "<rate>" with burst equal to rate,
+// "0" or an empty string disables the limit
+func ParseRateLimit(s string) (RateLimit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return RateLimit{}, nil
+	}
+
+	rateStr, burstStr, hasBurst := strings.Cut(s, ":")
+	rate, err := strconv.ParseFloat(rateStr, 64)
+	// only "0" disables, "0:5" or "0.0" are likely typos
+	if err != nil || !(rate > 0) || math.IsInf(rate, 0) {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q: rate must be a positive number", s)
+	}
+
+	burst := int(rate)
+	if hasBurst {
+		burst, err = strconv.Atoi(burstStr)
+		if err != nil {
+			return RateLimit{}, fmt.Errorf("invalid rate limit %q: burst must be an integer", s)
+		}
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return RateLimit{Rate: rate, Burst: burst}, nil
+}
+
+// RateLimiter is shared by every backend instance, so limits hold across the cluster
+type RateLimiter interface {
+	// Allow takes cost requests from the bucket of key, if denied it returns
+	// how long to wait until the request would be allowed
+	Allow(key string, limit RateLimit, cost int) (allowed bool, retryAfter time.Duration, err error)
+}
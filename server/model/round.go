@@ -0,0 +1,80 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrRoundNotActive = errors.New("round not active")
+	ErrInvalidRound   = errors.New("invalid round")
+)
+
+type RoundState string
+
+const (
+	RoundPending RoundState = "pending"
+	RoundRunning RoundState = "running"
+	RoundFrozen  RoundState = "frozen"
+	RoundEnded   RoundState = "ended"
+)
+
+// Scoring selects how the scoreboard of a round is ordered, both scores are
+// always kept
+type Scoring string
+
+const (
+	// ScoringConquers ranks by currently held fields, the default
+	ScoringConquers Scoring = "conquers"
+	// ScoringHoldTime ranks by Score.HoldPoints
+	ScoringHoldTime Scoring = "holdTime"
+)
+
+// Round is a game session, fields can be conquered between StartAt and EndAt.
+// From FreezeAt on players see the scoreboard as it was at FreezeAt, a zero
+// FreezeAt disables the freeze. An empty Scoring is ScoringConquers.
+type Round struct {
+	ID       int       `json:"id"`
+	StartAt  time.Time `json:"startAt"`
+	EndAt    time.Time `json:"endAt"`
+	FreezeAt time.Time `json:"freezeAt"`
+	Scoring  Scoring   `json:"scoring"`
+}
+
+func (r Round) State(now time.Time) RoundState {
+	switch {
+	case now.Before(r.StartAt):
+		return RoundPending
+	case !now.Before(r.EndAt):
+		return RoundEnded
+	case !r.FreezeAt.IsZero() && !now.Before(r.FreezeAt):
+		return RoundFrozen
+	default:
+		return RoundRunning
+	}
+}
+
+func (r Round) Validate() error {
+	if r.StartAt.IsZero() || r.EndAt.IsZero() {
+		return fmt.Errorf("%w: start and end time are required", ErrInvalidRound)
+	}
+	if !r.EndAt.After(r.StartAt) {
+		return fmt.Errorf("%w: end time must be after start time", ErrInvalidRound)
+	}
+	if !r.FreezeAt.IsZero() && (r.FreezeAt.Before(r.StartAt) || !r.FreezeAt.Before(r.EndAt)) {
+		return fmt.Errorf("%w: freeze time must be within the round", ErrInvalidRound)
+	}
+	switch r.Scoring {
+	case "", ScoringConquers, ScoringHoldTime:
+	default:
+		return fmt.Errorf("%w: scoring must be %s or %s", ErrInvalidRound, ScoringConquers, ScoringHoldTime)
+	}
+	return nil
+}
+
+// RoundStatus is the round as seen by players
+type RoundStatus struct {
+	Round
+	State RoundState `json:"state"`
+}
@@ -0,0 +1,146 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// snapshotMagic starts every encoded snapshot, the last byte is the format version
+var snapshotMagic = []byte("AWS\x01")
+
+var ErrInvalidSnapshot = errors.New("invalid map snapshot")
+
+// MapSnapshot is the whole map with owners as user ids
+type MapSnapshot struct {
+	// Version is the map version the snapshot was started at, it already
+	// contains every change up to Version and possibly some later ones
+	Version    uint64
+	FieldCount int
+	// Owners is keyed by conquer type and indexed by fieldID-1, 0 is a free field
+	Owners map[string][]uint32
+	// Users maps the ids in Owners to usernames
+	Users []User
+}
+
+// MarshalBinary encodes the snapshot as
+//
+//	"AWS\x01"
+//	uvarint version
+//	uvarint field count
+//	uvarint conquer type count, for each type in name order:
+//		uvarint name length, name
+//		field count uvarints, the owner id of fields 1 to field count
+//	uvarint user count, for each user:
+//		uvarint id
+//		uvarint username length, username
+//
+// free fields take a single byte, so a mostly empty map compresses well
+func (s *MapSnapshot) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(snapshotMagic) + len(s.Owners)*s.FieldCount + len(s.Users)*16)
+	buf.Write(snapshotMagic)
+
+	varint := make([]byte, binary.MaxVarintLen64)
+	putUvarint := func(v uint64) {
+		buf.Write(varint[:binary.PutUvarint(varint, v)])
+	}
+	putString := func(v string) {
+		putUvarint(uint64(len(v)))
+		buf.WriteString(v)
+	}
+
+	putUvarint(s.Version)
+	putUvarint(uint64(s.FieldCount))
+
+	conquerTypes := make([]string, 0, len(s.Owners))
+	for conquerType := range s.Owners {
+		conquerTypes = append(conquerTypes, conquerType)
+	}
+	sort.Strings(conquerTypes)
+	putUvarint(uint64(len(conquerTypes)))
+	for _, conquerType := range conquerTypes {
+		owners := s.Owners[conquerType]
+		if len(owners) != s.FieldCount {
+			return nil, fmt.Errorf("%w: %s has %d fields, want %d", ErrInvalidSnapshot, conquerType, len(owners), s.FieldCount)
+		}
+		putString(conquerType)
+		for _, owner := range owners {
+			putUvarint(uint64(owner))
+		}
+	}
+
+	putUvarint(uint64(len(s.Users)))
+	for _, user := range s.Users {
+		putUvarint(uint64(user.ID))
+		putString(user.Username)
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *MapSnapshot) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, snapshotMagic) {
+		return fmt.Errorf("%w: unknown format", ErrInvalidSnapshot)
+	}
+	reader := bytes.NewReader(data[len(snapshotMagic):])
+
+	var err error
+	uvarint := func() uint64 {
+		if err != nil {
+			return 0
+		}
+		var v uint64
+		v, err = binary.ReadUvarint(reader)
+		return v
+	}
+	// count reads a length and rejects ones longer than the remaining data
+	count := func() int {
+		n := uvarint()
+		if err == nil && n > uint64(reader.Len()) {
+			err = io.ErrUnexpectedEOF
+		}
+		return int(n)
+	}
+	readString := func() string {
+		n := count()
+		if err != nil {
+			return ""
+		}
+		v := make([]byte, n)
+		_, err = io.ReadFull(reader, v)
+		return string(v)
+	}
+
+	snapshot := MapSnapshot{
+		Version:    uvarint(),
+		FieldCount: count(),
+		Owners:     make(map[string][]uint32),
+	}
+	conquerTypes := count()
+	for i := 0; i < conquerTypes && err == nil; i++ {
+		conquerType := readString()
+		owners := make([]uint32, snapshot.FieldCount)
+		for j := range owners {
+			owners[j] = uint32(uvarint())
+		}
+		snapshot.Owners[conquerType] = owners
+	}
+	users := count()
+	snapshot.Users = make([]User, 0, users)
+	for i := 0; i < users && err == nil; i++ {
+		id := uvarint()
+		snapshot.Users = append(snapshot.Users, User{
+			ID:       int(id),
+			Username: readString(),
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+	}
+
+	*s = snapshot
+	return nil
+}
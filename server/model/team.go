@@ -0,0 +1,102 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrTeamExist     = errors.New("team already exists")
+	ErrTeamFull      = errors.New("team is full")
+	ErrAlreadyInTeam = errors.New("user is already in a team")
+	ErrNotInvited    = errors.New("user is not invited to the team")
+	ErrNotTeamMember = errors.New("user is not a member of the team")
+	ErrInvalidTeam   = errors.New("invalid team")
+	// ErrTeammateField is returned when conquering a field a teammate holds,
+	// steals within a team are blocked
+	ErrTeammateField = errors.New("field is held by a teammate")
+)
+
+const (
+	// MaxTeamSize matches the teams of four events run with
+	MaxTeamSize       = 4
+	MaxTeamNameLength = 32
+)
+
+// Team members are in join order, the first member created the team
+type Team struct {
+	ID      int      `json:"id"`
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+	// Invites are the users invited but not joined yet
+	Invites []string `json:"invites"`
+}
+
+func (t Team) HasMember(username string) bool {
+	for _, member := range t.Members {
+		if member == username {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTeamName counts the length in characters, not bytes
+func ValidateTeamName(name string) error {
+	if strings.TrimSpace(name) != name || name == "" || !utf8.ValidString(name) ||
+		utf8.RuneCountInString(name) > MaxTeamNameLength {
+		return fmt.Errorf("%w: name must be 1 to %d characters without surrounding spaces", ErrInvalidTeam, MaxTeamNameLength)
+	}
+	return nil
+}
+
+// TeamScore sums the scores of the team members, ConquerHistoryCount is
+// keyed by Protocol.ScoreColumn like Score
+type TeamScore struct {
+	Rank                int            `json:"rank"`
+	TeamID              int            `json:"teamID"`
+	Name                string         `json:"name"`
+	Members             []string       `json:"members"`
+	ConquerFieldCount   int            `json:"conquerFieldCount"`
+	ConquerHistoryCount map[string]int `json:"conquerHistoryCount"`
+	// HoldPoints is a point for every second the members held a field
+	HoldPoints int `json:"holdPoints"`
+}
+
+// TeamIndex maps the members of teams to their team id
+func TeamIndex(teams []Team) map[string]int {
+	index := make(map[string]int)
+	for _, team := range teams {
+		for _, member := range team.Members {
+			index[member] = team.ID
+		}
+	}
+	return index
+}
+
+// TeamOwner is a field owner with the team the owner is in, TeamID is 0 for
+// free fields and users without a team
+type TeamOwner struct {
+	Owner  string `json:"owner"`
+	TeamID int    `json:"teamID"`
+}
+
+// TeamRepresentation is Representation with the team of every owner,
+// teams maps usernames to team ids
+func (m *Map) TeamRepresentation(teams map[string]int) interface{} {
+	represent := make(map[int](map[string]TeamOwner))
+	for _, field := range m.Fields {
+		for _, conquerer := range field.Conquerer {
+			if _, ok := represent[field.FieldID]; !ok {
+				represent[field.FieldID] = make(map[string]TeamOwner)
+			}
+			represent[field.FieldID][conquerer.ConquerType] = TeamOwner{
+				Owner:  conquerer.Owner,
+				TeamID: teams[conquerer.Owner],
+			}
+		}
+	}
+	return represent
+}
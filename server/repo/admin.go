@@ -0,0 +1,151 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zodius/api-war/model"
+)
+
+// auditLimit is how many audit entries are kept
+const auditLimit = 10000
+
+func (r *repo) SetRole(username, role string) error {
+	return r.setUserField(username, "role", role)
+}
+
+func (r *repo) SetBanned(username string, banned bool) error {
+	value := 0
+	if banned {
+		value = 1
+	}
+	return r.setUserField(username, "banned", value)
+}
+
+func (r *repo) setUserField(username, field string, value interface{}) error {
+	userKey := fmt.Sprintf("user:%s", username)
+	exists, err := r.client.Exists(context.Background(), userKey).Result()
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return model.ErrNotFound
+	}
+	return r.client.HSet(context.Background(), userKey, field, value).Err()
+}
+
+func (r *repo) GetUserStats() ([]model.UserStats, error) {
+	users, err := r.GetUserList()
+	if err != nil {
+		return nil, err
+	}
+
+	protocols := model.Protocols()
+	accounts := make([]*redis.SliceCmd, len(users))
+	counts := make([]*redis.FloatCmd, len(users))
+	histories := make([][]*redis.FloatCmd, len(users))
+	if _, err := r.client.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		for i, user := range users {
+			accounts[i] = pipe.HMGet(context.Background(), fmt.Sprintf("user:%s", user.Username), "role", "banned")
+			counts[i] = pipe.ZScore(context.Background(), "score:conquerCount", user.Username)
+			histories[i] = make([]*redis.FloatCmd, len(protocols))
+			for j, protocol := range protocols {
+				histories[i][j] = pipe.ZScore(context.Background(),
+					fmt.Sprintf("score:conquerHistory:%s", protocol.Name), user.Username)
+			}
+		}
+		return nil
+	}); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	stats := make([]model.UserStats, 0, len(users))
+	for i, user := range users {
+		account := accounts[i].Val()
+		role, _ := account[0].(string)
+		banned, _ := account[1].(string)
+		if role == "" {
+			role = model.RolePlayer
+		}
+		userStats := model.UserStats{
+			ID:                  user.ID,
+			Username:            user.Username,
+			Role:                role,
+			Banned:              banned == "1",
+			ConquerFieldCount:   int(counts[i].Val()),
+			ConquerHistoryCount: make(map[string]int, len(protocols)),
+		}
+		for j, protocol := range protocols {
+			userStats.ConquerHistoryCount[protocol.ScoreColumn] = int(histories[i][j].Val())
+		}
+		stats = append(stats, userStats)
+	}
+	return stats, nil
+}
+
+func (r *repo) SetFieldOwner(fieldID int, conquerType, username string) (string, error) {
+	return setFieldOwnerScript.Run(context.Background(), r.client,
+		[]string{
+			fmt.Sprintf("fields:%s:conquerer", conquerType),
+			"score:conquerCount",
+			"map:version",
+			eventLogKey,
+		},
+		fieldID, username, conquerType, r.now().UnixMilli(),
+	).Text()
+}
+
+func (r *repo) WipeUserFields(username string) ([]model.MapChange, error) {
+	result, err := wipeUserFieldsScript.Run(context.Background(), r.client,
+		[]string{"score:conquerCount", "map:version", eventLogKey},
+		typeArgs(username, r.now().UnixMilli())...,
+	).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	version, _ := result[0].(int64)
+	freed := make([]model.MapChange, 0, (len(result)-1)/2)
+	for i := 1; i+1 < len(result); i += 2 {
+		conquerType, _ := result[i].(string)
+		fieldID, _ := result[i+1].(int64)
+		freed = append(freed, model.MapChange{
+			Version:     uint64(version),
+			FieldID:     int(fieldID),
+			ConquerType: conquerType,
+		})
+	}
+	return freed, nil
+}
+
+func (r *repo) AddAuditEntry(entry model.AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.TxPipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		pipe.LPush(context.Background(), "audit", data)
+		pipe.LTrim(context.Background(), "audit", 0, auditLimit-1)
+		return nil
+	})
+	return err
+}
+
+func (r *repo) GetAuditLog(limit int) ([]model.AuditEntry, error) {
+	values, err := r.client.LRange(context.Background(), "audit", 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]model.AuditEntry, 0, len(values))
+	for _, value := range values {
+		var entry model.AuditEntry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,139 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zodius/api-war/model"
+)
+
+// holdRankTTL is how long a hold time order is reused, held time only moves
+// the points once a second
+const holdRankTTL = time.Second
+
+// holdRankCache keeps the last hold time order of every score prefix, so
+// scoreboard reads don't rank every user each time
+type holdRankCache struct {
+	lock     sync.Mutex
+	rankings map[string]holdRanking
+}
+
+// reset drops the cached orders after a round change of this process, other
+// processes see it once their orders expire
+func (c *holdRankCache) reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	clear(c.rankings)
+}
+
+type holdRanking struct {
+	at        time.Time
+	endAt     int64
+	usernames []string
+	held      []int64
+}
+
+// scoringRound returns the scoring and the end in unix ms of the round the
+// scores under prefix belong to, frozen scores belong to the current round
+func (r *repo) scoringRound(prefix string) (model.Scoring, int64, error) {
+	key := "round"
+	if strings.HasPrefix(prefix, "archive:") {
+		key = prefix + "round"
+	}
+	values, err := r.client.HMGet(context.Background(), key, "scoring", "endAt").Result()
+	if err != nil {
+		return "", 0, err
+	}
+	scoring, _ := values[0].(string)
+	endAt, _ := values[1].(string)
+	end, _ := strconv.ParseInt(endAt, 10, 64)
+	return model.Scoring(scoring), end, nil
+}
+
+// holdTimes returns the ms the users held fields. Live scores add the time
+// since the last settle up to now or the end of the round, frozen and
+// archived scores were settled when they were copied.
+func (r *repo) holdTimes(prefix string, usernames []string, endAt int64) ([]int64, error) {
+	result := make([]int64, len(usernames))
+	if len(usernames) == 0 {
+		return result, nil
+	}
+
+	var held, since, counts *redis.FloatSliceCmd
+	if _, err := r.client.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		held = pipe.ZMScore(context.Background(), prefix+"score:holdTime", usernames...)
+		if prefix == "" {
+			since = pipe.ZMScore(context.Background(), "score:holdTime:since", usernames...)
+			counts = pipe.ZMScore(context.Background(), "score:conquerCount", usernames...)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	at := r.now().UnixMilli()
+	if endAt > 0 && endAt < at {
+		at = endAt
+	}
+	for i, value := range held.Val() {
+		result[i] = int64(value)
+		if prefix != "" {
+			continue
+		}
+		if settled := int64(since.Val()[i]); settled > 0 && at > settled {
+			result[i] += int64(counts.Val()[i]) * (at - settled)
+		}
+	}
+	return result, nil
+}
+
+// holdRanked returns every user under prefix ordered by held time, ties keep
+// the order of score:rank. The order is reused for holdRankTTL, callers must
+// not modify it.
+func (r *repo) holdRanked(prefix string, endAt int64) ([]string, []int64, error) {
+	now := r.now()
+	r.holdRanks.lock.Lock()
+	cached, ok := r.holdRanks.rankings[prefix]
+	r.holdRanks.lock.Unlock()
+	if ok && cached.endAt == endAt && !now.Before(cached.at) && now.Sub(cached.at) < holdRankTTL {
+		return cached.usernames, cached.held, nil
+	}
+
+	usernames, err := r.client.ZRevRange(context.Background(), prefix+"score:rank", 0, -1).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	held, err := r.holdTimes(prefix, usernames, endAt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	order := make([]int, len(usernames))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return held[order[i]] > held[order[j]]
+	})
+	rankedUsernames := make([]string, len(order))
+	rankedHeld := make([]int64, len(order))
+	for i, index := range order {
+		rankedUsernames[i] = usernames[index]
+		rankedHeld[i] = held[index]
+	}
+
+	r.holdRanks.lock.Lock()
+	r.holdRanks.rankings[prefix] = holdRanking{
+		at:        now,
+		endAt:     endAt,
+		usernames: rankedUsernames,
+		held:      rankedHeld,
+	}
+	r.holdRanks.lock.Unlock()
+	return rankedUsernames, rankedHeld, nil
+}
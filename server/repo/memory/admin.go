@@ -0,0 +1,149 @@
+package memory
+
+import (
+	"github.com/zodius/api-war/model"
+)
+
+// auditLimit matches the audit length of the redis repo
+const auditLimit = 10000
+
+func (r *repo) SetRole(username, role string) error {
+	return r.updateUser(username, func(user *model.User) {
+		user.Role = role
+	})
+}
+
+func (r *repo) SetBanned(username string, banned bool) error {
+	return r.updateUser(username, func(user *model.User) {
+		user.Banned = banned
+	})
+}
+
+func (r *repo) updateUser(username string, update func(user *model.User)) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	user, ok := r.users[username]
+	if !ok {
+		return model.ErrNotFound
+	}
+	update(&user)
+	r.users[username] = user
+	return nil
+}
+
+func (r *repo) GetUserStats() ([]model.UserStats, error) {
+	users, err := r.GetUserList()
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	stats := make([]model.UserStats, 0, len(users))
+	for _, listed := range users {
+		user := r.users[listed.Username]
+		role := user.Role
+		if role == "" {
+			role = model.RolePlayer
+		}
+		userStats := model.UserStats{
+			ID:                  user.ID,
+			Username:            user.Username,
+			Role:                role,
+			Banned:              user.Banned,
+			ConquerFieldCount:   r.conquerCount[user.Username],
+			ConquerHistoryCount: make(map[string]int),
+		}
+		for _, protocol := range model.Protocols() {
+			userStats.ConquerHistoryCount[protocol.ScoreColumn] = r.conquerHistory[protocol.Name][user.Username]
+		}
+		stats = append(stats, userStats)
+	}
+	return stats, nil
+}
+
+func (r *repo) SetFieldOwner(fieldID int, conquerType, username string) (string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	owners, ok := r.owners[conquerType]
+	if !ok {
+		owners = make(map[int]string)
+		r.owners[conquerType] = owners
+	}
+
+	previous := owners[fieldID]
+	if previous == username {
+		return previous, nil
+	}
+
+	if previous != "" {
+		delete(r.fieldSet(conquerType, previous), fieldID)
+		r.setCount(previous, r.conquerCount[previous]-1)
+	}
+	if username == "" {
+		delete(owners, fieldID)
+	} else {
+		owners[fieldID] = username
+		r.fieldSet(conquerType, username)[fieldID] = struct{}{}
+		r.setCount(username, r.conquerCount[username]+1)
+	}
+	r.version++
+	r.recordChange(fieldID, conquerType, username)
+	r.recordHistory(fieldID, conquerType, username)
+	return previous, nil
+}
+
+func (r *repo) WipeUserFields(username string) ([]model.MapChange, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	freed := make([]model.MapChange, 0)
+	for conquerType, users := range r.userFields {
+		for fieldID := range users[username] {
+			if r.owners[conquerType][fieldID] == username {
+				delete(r.owners[conquerType], fieldID)
+				// every freed field is recorded under the same version
+				if len(freed) == 0 {
+					r.version++
+				}
+				r.recordChange(fieldID, conquerType, "")
+				r.recordHistory(fieldID, conquerType, "")
+				freed = append(freed, model.MapChange{
+					Version:     r.version,
+					FieldID:     fieldID,
+					ConquerType: conquerType,
+				})
+			}
+		}
+		delete(users, username)
+	}
+	if count, ok := r.conquerCount[username]; ok && count != 0 {
+		r.setCount(username, 0)
+	}
+	return freed, nil
+}
+
+func (r *repo) AddAuditEntry(entry model.AuditEntry) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.audit = append(r.audit, entry)
+	if len(r.audit) > auditLimit {
+		r.audit = r.audit[len(r.audit)-auditLimit:]
+	}
+	return nil
+}
+
+func (r *repo) GetAuditLog(limit int) ([]model.AuditEntry, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	entries := make([]model.AuditEntry, 0, limit)
+	for i := len(r.audit) - 1; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, r.audit[i])
+	}
+	return entries, nil
+}
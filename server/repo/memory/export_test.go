@@ -0,0 +1,12 @@
+package memory
+
+import "github.com/zodius/api-war/model"
+
+// TokenIndexSize returns how many tokens the index of username holds,
+// expired ones included
+func TokenIndexSize(r model.Repo, username string) int {
+	m := r.(*repo)
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return len(m.userTokens[username])
+}
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/zodius/api-war/model"
+)
+
+func (r *repo) GetFieldHistory(fieldID int) ([]model.FieldChange, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	history := r.fieldHistory[fieldID]
+	result := make([]model.FieldChange, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		result = append(result, history[i])
+	}
+	return result, nil
+}
+
+func (r *repo) GetArchiveTimes() ([]time.Time, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	result := make([]time.Time, 0, len(r.archived))
+	for _, final := range r.archived {
+		result = append(result, final.archivedAt)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Before(result[j])
+	})
+	return result, nil
+}
+
+// recordHistory appends a change of a field and drops the oldest of its
+// conquer type beyond model.FieldHistoryLimit, caller must hold the write lock
+func (r *repo) recordHistory(fieldID int, conquerType, owner string) {
+	history := append(r.fieldHistory[fieldID], model.FieldChange{
+		// millisecond precision like the redis repo
+		Time:        time.UnixMilli(r.now().UnixMilli()),
+		ConquerType: conquerType,
+		Owner:       owner,
+	})
+	count, oldest := 0, 0
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].ConquerType == conquerType {
+			count++
+			oldest = i
+		}
+	}
+	if count > model.FieldHistoryLimit {
+		history = append(history[:oldest], history[oldest+1:]...)
+	}
+	r.fieldHistory[fieldID] = history
+}
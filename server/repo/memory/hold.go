@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/zodius/api-war/model"
+)
+
+// settleHold adds the time a user held fields since the last settle, it has
+// to run before the count changes, caller must hold the write lock
+func (r *repo) settleHold(username string, at int64) {
+	since, ok := r.holdSince[username]
+	if !ok {
+		since = at
+	}
+	if at < since {
+		return
+	}
+	r.holdTime[username] += int64(r.conquerCount[username]) * (at - since)
+	r.holdSince[username] = at
+}
+
+// settleAll settles every user up to now or limit if it is earlier, a zero
+// limit is ignored, caller must hold the write lock
+func (r *repo) settleAll(limit time.Time) {
+	at := r.now().UnixMilli()
+	if !limit.IsZero() && limit.UnixMilli() < at {
+		at = limit.UnixMilli()
+	}
+	for username := range r.users {
+		r.settleHold(username, at)
+	}
+}
+
+// held returns the ms a user held fields, live scores add the time since the
+// last settle up to holdAt
+func (s scores) held(username string) int64 {
+	held := s.holdTime[username]
+	if since, ok := s.holdSince[username]; ok && s.holdAt > since {
+		held += int64(s.conquerCount[username]) * (s.holdAt - since)
+	}
+	return held
+}
+
+// holdRanked orders usernames by held time, ties keep the given order
+func (s scores) holdRanked(usernames []string) {
+	sort.SliceStable(usernames, func(i, j int) bool {
+		return s.held(usernames[i]) > s.held(usernames[j])
+	})
+}
+
+// roundScoring is the scoring of the running round, caller must hold the read lock
+func (r *repo) roundScoring() model.Scoring {
+	if r.round == nil {
+		return ""
+	}
+	return r.round.Scoring
+}
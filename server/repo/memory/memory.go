@@ -0,0 +1,540 @@
+package memory
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zodius/api-war/model"
+)
+
+// tokenTTL matches the token expiry of the redis repo
+const tokenTTL = 15 * time.Minute
+
+type token struct {
+	username  string
+	createdAt time.Time
+	lastUsed  time.Time
+}
+
+// repo keeps the game state in process memory with the same semantics as the
+// redis repo, state is lost on restart and not shared between instances
+type repo struct {
+	lock *sync.RWMutex
+	now  func() time.Time
+
+	userCount int
+	users     map[string]model.User
+	tokens    map[string]token
+	// username -> set of tokens
+	userTokens map[string]map[string]struct{}
+	// conquerType -> fieldID -> owner
+	owners map[string]map[int]string
+	// conquerType -> username -> set of fieldID
+	userFields map[string]map[string]map[int]struct{}
+	// username -> currently held field count
+	conquerCount map[string]int
+	// username -> rankSeq when the count last changed, ties go to the lowest
+	achieved map[string]uint64
+	rankSeq  uint64
+	// username -> ms held fields up to holdSince
+	holdTime map[string]int64
+	// username -> unix ms of the last hold settle
+	holdSince map[string]int64
+	// conquerType -> username -> conquer count
+	conquerHistory map[string]map[string]int
+	// incremented by every change of a field owner
+	version uint64
+	// the last model.MapChangeLimit changes, oldest first
+	changes []model.MapChange
+	// oldest version GetMapChanges can start from
+	changesFloor uint64
+	// fieldID -> the last model.FieldHistoryLimit changes of every conquer
+	// type, oldest first
+	fieldHistory map[int][]model.FieldChange
+	// team id - 1 -> team
+	teams []model.Team
+	// conquerType -> fieldID -> unlock time, expired locks are dropped lazily
+	locks map[string]map[int]time.Time
+
+	round       *model.Round
+	frozenRound int
+	frozen      scores
+	// round id -> final state of the round
+	archived map[int]archive
+	// oldest first
+	audit []model.AuditEntry
+}
+
+type scores struct {
+	conquerCount   map[string]int
+	conquerHistory map[string]map[string]int
+	achieved       map[string]uint64
+	holdTime       map[string]int64
+	// nil once the held time was settled for good
+	holdSince map[string]int64
+	// unix ms live held time is computed up to
+	holdAt  int64
+	scoring model.Scoring
+}
+
+type archive struct {
+	archivedAt time.Time
+	round      *model.Round
+	owners     map[string]map[int]string
+	scores     scores
+}
+
+func NewRepo() model.Repo {
+	return NewRepoWithClock(time.Now)
+}
+
+// NewRepoWithClock returns a repo which reads the current time from now,
+// tests use it to expire tokens without waiting
+func NewRepoWithClock(now func() time.Time) model.Repo {
+	return &repo{
+		lock:           new(sync.RWMutex),
+		now:            now,
+		users:          make(map[string]model.User),
+		tokens:         make(map[string]token),
+		userTokens:     make(map[string]map[string]struct{}),
+		owners:         make(map[string]map[int]string),
+		userFields:     make(map[string]map[string]map[int]struct{}),
+		conquerCount:   make(map[string]int),
+		achieved:       make(map[string]uint64),
+		holdTime:       make(map[string]int64),
+		holdSince:      make(map[string]int64),
+		conquerHistory: make(map[string]map[string]int),
+		archived:       make(map[int]archive),
+		fieldHistory:   make(map[int][]model.FieldChange),
+		locks:          make(map[string]map[int]time.Time),
+	}
+}
+
+func (r *repo) GetUser(username string) (model.User, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	user, ok := r.users[username]
+	if !ok {
+		return model.User{}, model.ErrNotFound
+	}
+	return user, nil
+}
+
+func (r *repo) CreateUser(username, password string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.userCount++
+	r.users[username] = model.User{
+		ID:       r.userCount,
+		Username: username,
+		Password: password,
+	}
+	r.setCount(username, 0)
+	for _, protocol := range model.Protocols() {
+		r.history(protocol.Name)[username] = 0
+	}
+	return nil
+}
+
+func (r *repo) SetPassword(username, password string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	user, ok := r.users[username]
+	if !ok {
+		return model.ErrNotFound
+	}
+	user.Password = password
+	r.users[username] = user
+	return nil
+}
+
+func (r *repo) CreateToken(username string) (string, error) {
+	value, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	r.tokens[value] = token{
+		username:  username,
+		createdAt: now,
+		lastUsed:  now,
+	}
+	// tokens nobody presents again would stay in the index forever
+	for other := range r.userTokens[username] {
+		r.validToken(other)
+	}
+	tokens, ok := r.userTokens[username]
+	if !ok {
+		tokens = make(map[string]struct{})
+		r.userTokens[username] = tokens
+	}
+	tokens[value] = struct{}{}
+	return value, nil
+}
+
+func (r *repo) GetTokenUsername(value string) (string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	t, ok := r.validToken(value)
+	if !ok {
+		return "", model.ErrNotFound
+	}
+	t.lastUsed = r.now()
+	r.tokens[value] = t
+	return t.username, nil
+}
+
+func (r *repo) DeleteToken(value string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.deleteToken(value)
+	return nil
+}
+
+func (r *repo) DeleteUserTokens(username string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for value := range r.userTokens[username] {
+		r.deleteToken(value)
+	}
+	return nil
+}
+
+func (r *repo) GetUserTokens(username string) ([]model.Session, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	sessions := make([]model.Session, 0, len(r.userTokens[username]))
+	for value := range r.userTokens[username] {
+		t, ok := r.validToken(value)
+		if !ok {
+			continue
+		}
+		sessions = append(sessions, model.Session{
+			Token:      value,
+			CreatedAt:  t.createdAt,
+			LastUsedAt: t.lastUsed,
+		})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+// validToken returns an unexpired token and drops it if expired, caller must hold the write lock
+func (r *repo) validToken(value string) (token, bool) {
+	t, ok := r.tokens[value]
+	if !ok {
+		return token{}, false
+	}
+	if !r.now().Before(t.lastUsed.Add(tokenTTL)) {
+		r.deleteToken(value)
+		return token{}, false
+	}
+	return t, true
+}
+
+// deleteToken removes a token and its index entry, caller must hold the write lock
+func (r *repo) deleteToken(value string) {
+	t, ok := r.tokens[value]
+	if !ok {
+		return
+	}
+	delete(r.tokens, value)
+	delete(r.userTokens[t.username], value)
+}
+
+func (r *repo) GetMap(start, end int) (model.Map, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	protocols := model.Protocols()
+	fields := make([]model.Field, 0, end-start+1)
+	for fieldID := start; fieldID <= end; fieldID++ {
+		field := model.Field{
+			FieldID:   fieldID,
+			Conquerer: make([]model.Owner, 0, len(protocols)),
+		}
+		for _, protocol := range protocols {
+			field.Conquerer = append(field.Conquerer, model.Owner{
+				ConquerType: protocol.Name,
+				Owner:       r.owners[protocol.Name][fieldID],
+			})
+		}
+		fields = append(fields, field)
+	}
+	return model.Map{
+		Fields: fields,
+	}, nil
+}
+
+func (r *repo) GetFields(fieldIDs []int) (model.Map, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	protocols := model.Protocols()
+	fields := make([]model.Field, 0, len(fieldIDs))
+	for _, fieldID := range fieldIDs {
+		field := model.Field{
+			FieldID:   fieldID,
+			Conquerer: make([]model.Owner, 0, len(protocols)),
+		}
+		for _, protocol := range protocols {
+			field.Conquerer = append(field.Conquerer, model.Owner{
+				ConquerType: protocol.Name,
+				Owner:       r.owners[protocol.Name][fieldID],
+			})
+		}
+		fields = append(fields, field)
+	}
+	return model.Map{
+		Fields: fields,
+	}, nil
+}
+
+func (r *repo) GetUserList() ([]model.User, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	users := make([]model.User, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, model.User{
+			ID:       user.ID,
+			Username: user.Username,
+		})
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+	return users, nil
+}
+
+func (r *repo) GetUserConquerField(username string, conquerType string) ([]int, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	result := make([]int, 0, len(r.userFields[conquerType][username]))
+	for fieldID := range r.userFields[conquerType][username] {
+		result = append(result, fieldID)
+	}
+	sort.Ints(result)
+	return result, nil
+}
+
+func (r *repo) GetScoreboard(offset, limit int) ([]model.Score, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.liveScores().scoreboard(offset, limit), nil
+}
+
+func (r *repo) GetScoreNeighbourhood(username string, around int) ([]model.Score, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.liveScores().neighbourhood(username, around)
+}
+
+// liveScores are the scores of the running round, caller must hold the read lock
+func (r *repo) liveScores() scores {
+	s := scores{
+		conquerCount:   r.conquerCount,
+		conquerHistory: r.conquerHistory,
+		achieved:       r.achieved,
+		holdTime:       r.holdTime,
+		holdSince:      r.holdSince,
+		holdAt:         r.now().UnixMilli(),
+		scoring:        r.roundScoring(),
+	}
+	if r.round != nil && !r.round.EndAt.IsZero() && r.round.EndAt.UnixMilli() < s.holdAt {
+		s.holdAt = r.round.EndAt.UnixMilli()
+	}
+	return s
+}
+
+// setCount changes the held field count of a user and ranks the user behind
+// everyone who reached the count earlier, caller must hold the write lock
+func (r *repo) setCount(username string, count int) {
+	r.settleHold(username, r.now().UnixMilli())
+	r.conquerCount[username] = count
+	r.rankSeq++
+	r.achieved[username] = r.rankSeq
+}
+
+// ranked returns the usernames in rank order, caller must hold the read lock
+func (s scores) ranked() []string {
+	usernames := make([]string, 0, len(s.conquerCount))
+	for username := range s.conquerCount {
+		usernames = append(usernames, username)
+	}
+	sort.Slice(usernames, func(i, j int) bool {
+		a, b := usernames[i], usernames[j]
+		if s.conquerCount[a] != s.conquerCount[b] {
+			return s.conquerCount[a] > s.conquerCount[b]
+		}
+		return s.achieved[a] < s.achieved[b]
+	})
+	if s.scoring == model.ScoringHoldTime {
+		s.holdRanked(usernames)
+	}
+	return usernames
+}
+
+// scoreboard returns limit scores starting at offset, caller must hold the read lock
+func (s scores) scoreboard(offset, limit int) []model.Score {
+	return s.page(s.ranked(), offset, limit)
+}
+
+// page returns limit scores of the ranked usernames starting at offset,
+// caller must hold the read lock
+func (s scores) page(usernames []string, offset, limit int) []model.Score {
+	if offset >= len(usernames) {
+		return make([]model.Score, 0)
+	}
+	usernames = usernames[offset:min(offset+limit, len(usernames))]
+
+	scoreList := make([]model.Score, 0, len(usernames))
+	for i, username := range usernames {
+		score := model.Score{
+			Rank:                offset + i + 1,
+			Username:            username,
+			ConquerFieldCount:   s.conquerCount[username],
+			ConquerHistoryCount: make(map[string]int),
+			HoldPoints:          int(s.held(username) / 1000),
+		}
+		for _, protocol := range model.Protocols() {
+			score.ConquerHistoryCount[protocol.ScoreColumn] = s.conquerHistory[protocol.Name][username]
+		}
+		scoreList = append(scoreList, score)
+	}
+	return scoreList
+}
+
+// neighbourhood returns the scores from around ranks above to around ranks
+// below a user, caller must hold the read lock
+func (s scores) neighbourhood(username string, around int) ([]model.Score, error) {
+	if _, ok := s.conquerCount[username]; !ok {
+		return nil, model.ErrNotFound
+	}
+	usernames := s.ranked()
+	index := slices.Index(usernames, username)
+	if index < 0 {
+		return nil, model.ErrNotFound
+	}
+	offset := max(index-around, 0)
+	return s.page(usernames, offset, index+around+1-offset), nil
+}
+
+func (r *repo) Conquer(fieldID int, conquerType, username string, lock model.FieldLock) (string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.conquer(fieldID, conquerType, username, lock)
+}
+
+func (r *repo) ConquerFields(fieldIDs []int, conquerType, username string, lock model.FieldLock) ([]model.ConquerOutcome, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	outcomes := make([]model.ConquerOutcome, len(fieldIDs))
+	for i, fieldID := range fieldIDs {
+		previous, err := r.conquer(fieldID, conquerType, username, lock)
+		outcomes[i] = model.ConquerOutcome{PreviousOwner: previous, Err: err}
+	}
+	return outcomes, nil
+}
+
+// conquer is Conquer, caller must hold the write lock
+func (r *repo) conquer(fieldID int, conquerType, username string, lock model.FieldLock) (string, error) {
+	owners, ok := r.owners[conquerType]
+	if !ok {
+		owners = make(map[int]string)
+		r.owners[conquerType] = owners
+	}
+
+	previous := owners[fieldID]
+	if previous == username {
+		return previous, nil
+	}
+	locks, ok := r.locks[conquerType]
+	if !ok {
+		locks = make(map[int]time.Time)
+		r.locks[conquerType] = locks
+	}
+	now := r.now()
+	if unlockAt, ok := locks[fieldID]; ok {
+		if now.Before(unlockAt) {
+			return "", &model.FieldLockedError{UnlockAt: unlockAt}
+		}
+		delete(locks, fieldID)
+	}
+	if team := r.users[username].TeamID; previous != "" && team != 0 && team == r.users[previous].TeamID {
+		return "", model.ErrTeammateField
+	}
+
+	owners[fieldID] = username
+	r.fieldSet(conquerType, username)[fieldID] = struct{}{}
+	if previous != "" {
+		delete(r.fieldSet(conquerType, previous), fieldID)
+		r.setCount(previous, r.conquerCount[previous]-1)
+	}
+	r.setCount(username, r.conquerCount[username]+1)
+	r.history(conquerType)[username]++
+	r.version++
+	r.recordChange(fieldID, conquerType, username)
+	r.recordHistory(fieldID, conquerType, username)
+	// millisecond precision like the redis repo
+	if ms := lock.For(previous).Milliseconds(); ms > 0 {
+		locks[fieldID] = time.UnixMilli(now.UnixMilli() + ms)
+	}
+	return previous, nil
+}
+
+// fieldSet returns the fields of a user for a conquer type, caller must hold the write lock
+func (r *repo) fieldSet(conquerType, username string) map[int]struct{} {
+	users, ok := r.userFields[conquerType]
+	if !ok {
+		users = make(map[string]map[int]struct{})
+		r.userFields[conquerType] = users
+	}
+	fields, ok := users[username]
+	if !ok {
+		fields = make(map[int]struct{})
+		users[username] = fields
+	}
+	return fields
+}
+
+// history returns the conquer history counts of a conquer type, caller must hold the write lock
+func (r *repo) history(conquerType string) map[string]int {
+	history, ok := r.conquerHistory[conquerType]
+	if !ok {
+		history = make(map[string]int)
+		r.conquerHistory[conquerType] = history
+	}
+	return history
+}
+
+func randomToken() (string, error) {
+	bytes := make([]byte, 20)
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
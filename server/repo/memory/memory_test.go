@@ -0,0 +1,35 @@
+package memory_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zodius/api-war/repo/memory"
+	"github.com/zodius/api-war/repo/repotest"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Harness {
+		var lock sync.Mutex
+		now := time.Now()
+		clock := func() time.Time {
+			lock.Lock()
+			defer lock.Unlock()
+			return now
+		}
+
+		repo := memory.NewRepoWithClock(clock)
+		return repotest.Harness{
+			Repo: repo,
+			Advance: func(d time.Duration) {
+				lock.Lock()
+				defer lock.Unlock()
+				now = now.Add(d)
+			},
+			TokenIndexSize: func(username string) int {
+				return memory.TokenIndexSize(repo, username)
+			},
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package memory
+
+import (
+	"maps"
+	"sort"
+	"time"
+
+	"github.com/zodius/api-war/model"
+)
+
+func (r *repo) GetRound() (model.Round, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if r.round == nil {
+		return model.Round{}, model.ErrNotFound
+	}
+	return *r.round, nil
+}
+
+func (r *repo) SetRound(round model.Round) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.round = &round
+	return nil
+}
+
+func (r *repo) FreezeScoreboard(roundID int) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.archived[roundID]; ok || r.frozenRound == roundID {
+		return nil
+	}
+	r.frozenRound = roundID
+	var freezeAt time.Time
+	if r.round != nil && r.round.ID == roundID {
+		freezeAt = r.round.FreezeAt
+	}
+	r.settleAll(freezeAt)
+	r.frozen = scores{
+		conquerCount:   maps.Clone(r.conquerCount),
+		conquerHistory: make(map[string]map[string]int, len(r.conquerHistory)),
+		achieved:       maps.Clone(r.achieved),
+		holdTime:       maps.Clone(r.holdTime),
+	}
+	for conquerType, history := range r.conquerHistory {
+		r.frozen.conquerHistory[conquerType] = maps.Clone(history)
+	}
+	return nil
+}
+
+func (r *repo) GetFrozenScoreboard(roundID, offset, limit int) ([]model.Score, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if r.frozenRound == 0 || r.frozenRound != roundID {
+		return nil, model.ErrNotFound
+	}
+	return r.frozenScores().scoreboard(offset, limit), nil
+}
+
+func (r *repo) GetFrozenScoreNeighbourhood(roundID int, username string, around int) ([]model.Score, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if r.frozenRound == 0 || r.frozenRound != roundID {
+		return nil, model.ErrNotFound
+	}
+	return r.frozenScores().neighbourhood(username, around)
+}
+
+// frozenScores are ranked by the scoring of the running round like the redis
+// repo, caller must hold the read lock
+func (r *repo) frozenScores() scores {
+	frozen := r.frozen
+	frozen.scoring = r.roundScoring()
+	return frozen
+}
+
+func (r *repo) ArchiveRound(roundID int) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.archived[roundID]; ok {
+		return nil
+	}
+
+	var endAt time.Time
+	if r.round != nil && r.round.ID == roundID {
+		endAt = r.round.EndAt
+	}
+	r.settleAll(endAt)
+	final := archive{
+		archivedAt: r.now(),
+		owners:     r.owners,
+		scores:     r.liveScores(),
+	}
+	final.scores.holdSince = nil
+	final.scores.scoring = ""
+	if r.round != nil && r.round.ID == roundID {
+		round := *r.round
+		final.round = &round
+		final.scores.scoring = round.Scoring
+	}
+	r.archived[roundID] = final
+
+	// every user starts the next round with empty holdings
+	r.owners = make(map[string]map[int]string)
+	r.userFields = make(map[string]map[string]map[int]struct{})
+	r.conquerCount = make(map[string]int, len(r.users))
+	r.achieved = make(map[string]uint64, len(r.users))
+	r.conquerHistory = make(map[string]map[string]int)
+	r.holdTime = make(map[string]int64, len(r.users))
+	r.holdSince = make(map[string]int64, len(r.users))
+	// locks end with their round
+	r.locks = make(map[string]map[int]time.Time)
+	// in id order like the redis repo, so ties go to the older account
+	users := make([]model.User, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+	for _, user := range users {
+		r.setCount(user.Username, 0)
+	}
+	r.frozenRound = 0
+	r.frozen = scores{}
+	// changes before the reset can't be applied to the new map
+	r.version++
+	r.changes = nil
+	r.changesFloor = r.version
+	return nil
+}
+
+func (r *repo) GetArchivedScoreboard(roundID, offset, limit int) ([]model.Score, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	final, ok := r.archived[roundID]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+	return final.scores.scoreboard(offset, limit), nil
+}
@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/zodius/api-war/model"
+)
+
+func (r *repo) GetMapVersion() (uint64, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.version, nil
+}
+
+func (r *repo) GetMapSnapshot(fieldCount int) (model.MapSnapshot, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	users := make([]model.User, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, model.User{
+			ID:       user.ID,
+			Username: user.Username,
+		})
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	snapshot := model.MapSnapshot{
+		Version:    r.version,
+		FieldCount: fieldCount,
+		Owners:     make(map[string][]uint32),
+		Users:      users,
+	}
+	for _, protocol := range model.Protocols() {
+		owners := make([]uint32, fieldCount)
+		for fieldID, username := range r.owners[protocol.Name] {
+			if fieldID < 1 || fieldID > fieldCount {
+				continue
+			}
+			owners[fieldID-1] = uint32(r.users[username].ID)
+		}
+		snapshot.Owners[protocol.Name] = owners
+	}
+	return snapshot, nil
+}
+
+func (r *repo) GetMapChanges(since uint64) (model.MapChanges, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if since < r.changesFloor || since > r.version {
+		return model.MapChanges{}, &model.ResyncRequiredError{Version: r.version}
+	}
+
+	start := sort.Search(len(r.changes), func(i int) bool {
+		return r.changes[i].Version > since
+	})
+	return model.MapChanges{
+		Version: r.version,
+		Changes: append([]model.MapChange{}, r.changes[start:]...),
+	}, nil
+}
+
+// recordChange appends a change at the current version and drops the oldest
+// beyond model.MapChangeLimit, caller must hold the write lock
+func (r *repo) recordChange(fieldID int, conquerType, owner string) {
+	r.changes = append(r.changes, model.MapChange{
+		Version:     r.version,
+		FieldID:     fieldID,
+		ConquerType: conquerType,
+		Owner:       owner,
+	})
+	if trim := len(r.changes) - model.MapChangeLimit; trim > 0 {
+		r.changesFloor = r.changes[trim-1].Version
+		r.changes = r.changes[trim:]
+	}
+}
@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/zodius/api-war/model"
+)
+
+func (r *repo) CreateTeam(name, username string) (model.Team, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	user := r.users[username]
+	if user.TeamID != 0 {
+		return model.Team{}, model.ErrAlreadyInTeam
+	}
+	for _, team := range r.teams {
+		if team.Name == name {
+			return model.Team{}, model.ErrTeamExist
+		}
+	}
+
+	team := model.Team{
+		ID:      len(r.teams) + 1,
+		Name:    name,
+		Members: []string{username},
+		Invites: []string{},
+	}
+	r.teams = append(r.teams, team)
+	user.TeamID = team.ID
+	r.users[username] = user
+	return copyTeam(team), nil
+}
+
+func (r *repo) GetTeam(teamID int) (model.Team, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	team, ok := r.team(teamID)
+	if !ok {
+		return model.Team{}, model.ErrNotFound
+	}
+	return copyTeam(*team), nil
+}
+
+func (r *repo) GetTeams() ([]model.Team, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	teams := make([]model.Team, 0, len(r.teams))
+	for _, team := range r.teams {
+		teams = append(teams, copyTeam(team))
+	}
+	return teams, nil
+}
+
+func (r *repo) InviteToTeam(teamID int, username string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	team, ok := r.team(teamID)
+	if !ok {
+		return model.ErrNotFound
+	}
+	if _, ok := r.users[username]; !ok {
+		return model.ErrNotFound
+	}
+	if !slices.Contains(team.Invites, username) {
+		team.Invites = append(team.Invites, username)
+		sort.Strings(team.Invites)
+	}
+	return nil
+}
+
+func (r *repo) JoinTeam(teamID int, username string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	user := r.users[username]
+	if user.TeamID != 0 {
+		return model.ErrAlreadyInTeam
+	}
+	team, ok := r.team(teamID)
+	if !ok || !slices.Contains(team.Invites, username) {
+		return model.ErrNotInvited
+	}
+	if len(team.Members) >= model.MaxTeamSize {
+		return model.ErrTeamFull
+	}
+
+	team.Members = append(team.Members, username)
+	team.Invites = slices.DeleteFunc(team.Invites, func(invited string) bool {
+		return invited == username
+	})
+	user.TeamID = teamID
+	r.users[username] = user
+	return nil
+}
+
+func (r *repo) GetTeamScores() ([]model.TeamScore, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.teamScores(r.liveScores()), nil
+}
+
+func (r *repo) GetFrozenTeamScores(roundID int) ([]model.TeamScore, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if r.frozenRound == 0 || r.frozenRound != roundID {
+		return nil, model.ErrNotFound
+	}
+	return r.teamScores(r.frozen), nil
+}
+
+// teamScores sums the member scores of every team, caller must hold the read lock
+func (r *repo) teamScores(s scores) []model.TeamScore {
+	teamScores := make([]model.TeamScore, 0, len(r.teams))
+	for _, team := range r.teams {
+		score := model.TeamScore{
+			TeamID:              team.ID,
+			Name:                team.Name,
+			Members:             slices.Clone(team.Members),
+			ConquerHistoryCount: make(map[string]int),
+		}
+		var held int64
+		for _, member := range team.Members {
+			score.ConquerFieldCount += s.conquerCount[member]
+			for _, protocol := range model.Protocols() {
+				score.ConquerHistoryCount[protocol.ScoreColumn] += s.conquerHistory[protocol.Name][member]
+			}
+			held += s.held(member)
+		}
+		score.HoldPoints = int(held / 1000)
+		teamScores = append(teamScores, score)
+	}
+	return teamScores
+}
+
+// team returns a team for changes, caller must hold the lock
+func (r *repo) team(teamID int) (*model.Team, bool) {
+	if teamID < 1 || teamID > len(r.teams) {
+		return nil, false
+	}
+	return &r.teams[teamID-1], true
+}
+
+// copyTeam keeps callers from changing the stored member lists
+func copyTeam(team model.Team) model.Team {
+	team.Members = slices.Clone(team.Members)
+	team.Invites = slices.Clone(team.Invites)
+	return team
+}
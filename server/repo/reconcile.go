@@ -0,0 +1,136 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zodius/api-war/model"
+)
+
+// Reconcile rebuilds every user bitmap and score:conquerCount from the
+// fields:<type>:conquerer owner hashes, which are the source of truth for
+// field ownership. Users whose count changed or who are missing from
+// score:rank are ranked again, behind everyone with the same count.
+// Conquers during reconciliation may be lost from the derived keys, so run
+// it while the game is paused.
+func Reconcile(client *redis.Client) error {
+	ctx := context.Background()
+
+	users, err := client.ZRange(ctx, "users", 0, -1).Result()
+	if err != nil {
+		return err
+	}
+
+	holdings := make(map[string]int, len(users))
+	for _, username := range users {
+		holdings[username] = 0
+	}
+
+	for _, protocol := range model.Protocols() {
+		bitmaps := make(map[string][]byte, len(users))
+		for _, username := range users {
+			bitmaps[username] = []byte{0}
+		}
+
+		// scan owner hash to avoid blocking redis on large maps, a scan can
+		// return a field more than once so owners are collected by field
+		owners := make(map[int]string)
+		ownerKey := fmt.Sprintf("fields:%s:conquerer", protocol.Name)
+		iter := client.HScan(ctx, ownerKey, 0, "", 10000).Iterator()
+		for iter.Next(ctx) {
+			fieldIDStr := iter.Val()
+			if !iter.Next(ctx) {
+				break
+			}
+			owner := iter.Val()
+
+			fieldID, err := strconv.Atoi(fieldIDStr)
+			if err != nil {
+				return fmt.Errorf("invalid field id %q in %s: %w", fieldIDStr, ownerKey, err)
+			}
+			owners[fieldID] = owner
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+		for fieldID, owner := range owners {
+			bitmaps[owner] = setBit(bitmaps[owner], fieldID)
+			holdings[owner]++
+		}
+
+		// replace bitmaps
+		if _, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for username, bitmap := range bitmaps {
+				pipe.Set(ctx, fmt.Sprintf("user:%s:conquerField:%s", username, protocol.Name), bitmap, 0)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	var previousCounts, ranks []float64
+	if len(users) > 0 {
+		if previousCounts, err = client.ZMScore(ctx, "score:conquerCount", users...).Result(); err != nil {
+			return err
+		}
+		// a missing member scores 0, a ranked one never does
+		if ranks, err = client.ZMScore(ctx, "score:rank", users...).Result(); err != nil {
+			return err
+		}
+	}
+
+	// held time is counted with the old counts up to now
+	settle := []interface{}{time.Now().UnixMilli()}
+	for i, username := range users {
+		if previousCounts[i] != float64(holdings[username]) {
+			settle = append(settle, username)
+		}
+	}
+	if len(settle) > 1 {
+		if err := settleHoldScript.Run(ctx, client, nil, settle...).Err(); err != nil {
+			return err
+		}
+	}
+
+	// replace field counts
+	if _, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for username, count := range holdings {
+			pipe.ZAdd(ctx, "score:conquerCount", redis.Z{
+				Score:  float64(count),
+				Member: username,
+			})
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// in id order, so ties between reranked users go to the older account
+	rerank := make([]interface{}, 0)
+	for i, username := range users {
+		if ranks[i] == 0 || previousCounts[i] != float64(holdings[username]) {
+			rerank = append(rerank, username)
+		}
+	}
+	if len(rerank) == 0 {
+		return nil
+	}
+	return updateRankScript.Run(ctx, client, nil, rerank...).Err()
+}
+
+// setBit sets bit offset in a redis bitmap, growing it as needed
+func setBit(bitmap []byte, offset int) []byte {
+	index := offset / 8
+	if index >= len(bitmap) {
+		grown := make([]byte, index+1)
+		copy(grown, bitmap)
+		bitmap = grown
+	}
+	// redis bitmaps are big endian within a byte
+	bitmap[index] |= 0x80 >> (offset % 8)
+	return bitmap
+}
@@ -0,0 +1,459 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zodius/api-war/model"
+)
+
+// eventLogKey is the stream every change of a field owner is appended to,
+// entries have an action field and depending on it:
+//
+//	conquer, set: type, field, user, previous, time
+//	wipe: user, time
+//	reset: round, time
+//
+// set is an admin change which doesn't count as a conquer, an empty user
+// frees the field, reset is the end of a round
+const eventLogKey = "events:conquer"
+
+// replayBatchSize is the number of events read from the log at once
+const replayBatchSize = 10000
+
+// maxDifferences bounds the differences Verify reports
+const maxDifferences = 100
+
+var ErrTargetNotEmpty = errors.New("replay target is not empty")
+
+// replayState is the field ownership rebuilt from the event log
+type replayState struct {
+	// conquerType -> fieldID -> owner
+	owners map[string]map[int]string
+	// conquerType -> username -> conquer count
+	history map[string]map[string]int
+	// username -> held fields over every conquer type
+	counts map[string]int
+	// held time in ms and the last settle in unix ms like settleHoldLua
+	held  map[string]int64
+	since map[string]int64
+	// ranks is the sequence number of the last updateRank of a user, seq is
+	// the last one handed out and survives a reset like score:rank:seq
+	ranks map[string]int64
+	seq   int64
+}
+
+func newReplayState() *replayState {
+	state := &replayState{
+		owners:  make(map[string]map[int]string),
+		history: make(map[string]map[string]int),
+		counts:  make(map[string]int),
+		held:    make(map[string]int64),
+		since:   make(map[string]int64),
+		ranks:   make(map[string]int64),
+	}
+	for _, protocol := range model.Protocols() {
+		state.owners[protocol.Name] = make(map[int]string)
+		state.history[protocol.Name] = make(map[string]int)
+	}
+	return state
+}
+
+// settle adds the time username held fields since the last settle
+func (s *replayState) settle(username string, at int64) {
+	since, ok := s.since[username]
+	if !ok {
+		since = at
+	}
+	if at < since {
+		return
+	}
+	if count := s.counts[username]; count > 0 && at > since {
+		s.held[username] += int64(count) * (at - since)
+	}
+	s.since[username] = at
+}
+
+// move changes the field count of username in the order of the scripts
+func (s *replayState) move(username string, delta int, at int64) {
+	s.settle(username, at)
+	s.counts[username] += delta
+	s.rank(username)
+}
+
+func (s *replayState) rank(username string) {
+	s.seq++
+	s.ranks[username] = s.seq
+}
+
+func (s *replayState) apply(message redis.XMessage) error {
+	value := func(name string) string {
+		v, _ := message.Values[name].(string)
+		return v
+	}
+	field := func() (map[int]string, int, error) {
+		owners, ok := s.owners[value("type")]
+		if !ok {
+			return nil, 0, fmt.Errorf("%w: %s", model.ErrUnknownProtocol, value("type"))
+		}
+		fieldID, err := strconv.Atoi(value("field"))
+		return owners, fieldID, err
+	}
+	at, err := strconv.ParseInt(value("time"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid time %q: %w", value("time"), err)
+	}
+
+	switch value("action") {
+	case "conquer", "set":
+		owners, fieldID, err := field()
+		if err != nil {
+			return err
+		}
+		if previous := owners[fieldID]; previous != "" {
+			s.move(previous, -1, at)
+		}
+		if value("user") == "" {
+			delete(owners, fieldID)
+		} else {
+			owners[fieldID] = value("user")
+			s.move(value("user"), 1, at)
+		}
+		if value("action") == "conquer" {
+			s.history[value("type")][value("user")]++
+		}
+	case "wipe":
+		for _, owners := range s.owners {
+			for fieldID, owner := range owners {
+				if owner == value("user") {
+					delete(owners, fieldID)
+				}
+			}
+		}
+		s.settle(value("user"), at)
+		if s.counts[value("user")] != 0 {
+			s.counts[value("user")] = 0
+			s.rank(value("user"))
+		}
+	case "reset":
+		seq := s.seq
+		*s = *newReplayState()
+		s.seq = seq
+	default:
+		return fmt.Errorf("unknown action %q", value("action"))
+	}
+	return nil
+}
+
+// Replay rebuilds the owner hashes, user bitmaps and score zsets of source
+// from its event log into target, which has to be an empty keyspace.
+// Users ranked by no event since the last reset are ranked in id order
+// ahead of the events, the log doesn't record when they were created.
+// It returns the number of replayed events.
+func Replay(source, target *redis.Client) (int, error) {
+	ctx := context.Background()
+
+	size, err := target.DBSize(ctx).Result()
+	if err != nil {
+		return 0, err
+	}
+	if size != 0 {
+		return 0, ErrTargetNotEmpty
+	}
+
+	users, err := source.ZRange(ctx, "users", 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	state := newReplayState()
+	// users without an event take the sequence numbers before the events
+	state.seq = int64(len(users))
+	events := 0
+	start := "-"
+	for {
+		messages, err := source.XRangeN(ctx, eventLogKey, start, "+", replayBatchSize).Result()
+		if err != nil {
+			return events, err
+		}
+		for _, message := range messages {
+			if err := state.apply(message); err != nil {
+				return events, fmt.Errorf("event %s: %w", message.ID, err)
+			}
+			events++
+		}
+		if len(messages) < replayBatchSize {
+			break
+		}
+		start = "(" + messages[len(messages)-1].ID
+	}
+
+	// every user starts with empty holdings like in CreateUser
+	holdings := make(map[string]int, len(users))
+	for _, username := range users {
+		holdings[username] = 0
+	}
+	for conquerType, owners := range state.owners {
+		bitmaps := make(map[string][]byte, len(users))
+		for _, username := range users {
+			bitmaps[username] = []byte{0}
+		}
+		fields := make([]interface{}, 0, 2*len(owners))
+		for fieldID, owner := range owners {
+			fields = append(fields, fieldID, owner)
+			bitmaps[owner] = setBit(bitmaps[owner], fieldID)
+			holdings[owner]++
+		}
+
+		if _, err := target.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for start := 0; start < len(fields); start += 2 * replayBatchSize {
+				end := min(start+2*replayBatchSize, len(fields))
+				pipe.HSet(ctx, fmt.Sprintf("fields:%s:conquerer", conquerType), fields[start:end]...)
+			}
+			for username, bitmap := range bitmaps {
+				pipe.Set(ctx, fmt.Sprintf("user:%s:conquerField:%s", username, conquerType), bitmap, 0)
+			}
+			history := state.history[conquerType]
+			for _, username := range users {
+				pipe.ZAdd(ctx, fmt.Sprintf("score:conquerHistory:%s", conquerType), redis.Z{
+					Score:  float64(history[username]),
+					Member: username,
+				})
+			}
+			return nil
+		}); err != nil {
+			return events, err
+		}
+	}
+
+	if _, err := target.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, username := range users {
+			count := holdings[username]
+			pipe.ZAdd(ctx, "score:conquerCount", redis.Z{
+				Score:  float64(count),
+				Member: username,
+			})
+			seq, ok := state.ranks[username]
+			if !ok {
+				seq = int64(i)
+			}
+			// the score of updateRankLua
+			pipe.ZAdd(ctx, "score:rank", redis.Z{
+				Score:  float64(count)*4294967296 + float64(4294967295-seq%4294967296),
+				Member: username,
+			})
+			if held := state.held[username]; held > 0 {
+				pipe.ZAdd(ctx, "score:holdTime", redis.Z{Score: float64(held), Member: username})
+			}
+			if since, ok := state.since[username]; ok {
+				pipe.ZAdd(ctx, "score:holdTime:since", redis.Z{Score: float64(since), Member: username})
+			}
+		}
+		pipe.Set(ctx, "score:rank:seq", state.seq, 0)
+		return nil
+	}); err != nil {
+		return events, err
+	}
+	return events, nil
+}
+
+// Verify compares the state Replay wrote to rebuilt with the live state and
+// returns the differences, at most maxDifferences of them
+func Verify(live, rebuilt *redis.Client) ([]string, error) {
+	ctx := context.Background()
+
+	users, err := live.ZRange(ctx, "users", 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var differences []string
+	differ := func(format string, args ...interface{}) bool {
+		differences = append(differences, fmt.Sprintf(format, args...))
+		return len(differences) >= maxDifferences
+	}
+
+	for _, protocol := range model.Protocols() {
+		ownerKey := fmt.Sprintf("fields:%s:conquerer", protocol.Name)
+		liveOwners, err := readHash(live, ownerKey)
+		if err != nil {
+			return nil, err
+		}
+		rebuiltOwners, err := readHash(rebuilt, ownerKey)
+		if err != nil {
+			return nil, err
+		}
+		for fieldID, owner := range liveOwners {
+			if rebuiltOwners[fieldID] != owner && differ("%s field %s: live %q, rebuilt %q", ownerKey, fieldID, owner, rebuiltOwners[fieldID]) {
+				return differences, nil
+			}
+		}
+		for fieldID, owner := range rebuiltOwners {
+			if _, ok := liveOwners[fieldID]; !ok && differ("%s field %s: live free, rebuilt %q", ownerKey, fieldID, owner) {
+				return differences, nil
+			}
+		}
+
+		for _, username := range users {
+			bitmapKey := fmt.Sprintf("user:%s:conquerField:%s", username, protocol.Name)
+			liveBitmap, err := readBitmap(live, bitmapKey)
+			if err != nil {
+				return nil, err
+			}
+			rebuiltBitmap, err := readBitmap(rebuilt, bitmapKey)
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(liveBitmap, rebuiltBitmap) && differ("%s differs", bitmapKey) {
+				return differences, nil
+			}
+		}
+	}
+
+	if len(users) == 0 {
+		return differences, nil
+	}
+	scoreKeys := []string{"score:conquerCount"}
+	for _, protocol := range model.Protocols() {
+		scoreKeys = append(scoreKeys, fmt.Sprintf("score:conquerHistory:%s", protocol.Name))
+	}
+	for _, key := range scoreKeys {
+		liveScores, err := live.ZMScore(ctx, key, users...).Result()
+		if err != nil {
+			return nil, err
+		}
+		rebuiltScores, err := rebuilt.ZMScore(ctx, key, users...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i, username := range users {
+			if liveScores[i] != rebuiltScores[i] && differ("%s %s: live %v, rebuilt %v", key, username, liveScores[i], rebuiltScores[i]) {
+				return differences, nil
+			}
+		}
+	}
+
+	counts, err := live.ZMScore(ctx, "score:conquerCount", users...).Result()
+	if err != nil {
+		return nil, err
+	}
+	if difference, err := verifyRank(live, rebuilt, users, counts); err != nil {
+		return nil, err
+	} else if difference != "" && differ("%s", difference) {
+		return differences, nil
+	}
+
+	// the last settle differs where the live game settled without an event,
+	// so held times are compared at the same instant with the live counts
+	at := time.Now().UnixMilli()
+	liveHeld, err := heldAt(live, users, counts, at)
+	if err != nil {
+		return nil, err
+	}
+	rebuiltHeld, err := heldAt(rebuilt, users, counts, at)
+	if err != nil {
+		return nil, err
+	}
+	for i, username := range users {
+		if liveHeld[i] != rebuiltHeld[i] && differ("score:holdTime %s: live %d, rebuilt %d", username, liveHeld[i], rebuiltHeld[i]) {
+			return differences, nil
+		}
+	}
+	return differences, nil
+}
+
+// verifyRank compares the order of score:rank for users holding fields, the
+// order of users without fields depends on when they were created. It returns
+// the first difference, empty if there is none.
+func verifyRank(live, rebuilt *redis.Client, users []string, counts []float64) (string, error) {
+	holding := make(map[string]bool, len(users))
+	for i, username := range users {
+		holding[username] = counts[i] > 0
+	}
+	ranked := func(client *redis.Client) ([]string, error) {
+		usernames, err := client.ZRevRange(context.Background(), "score:rank", 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+		result := make([]string, 0, len(usernames))
+		for _, username := range usernames {
+			if holding[username] {
+				result = append(result, username)
+			}
+		}
+		return result, nil
+	}
+
+	liveRanked, err := ranked(live)
+	if err != nil {
+		return "", err
+	}
+	rebuiltRanked, err := ranked(rebuilt)
+	if err != nil {
+		return "", err
+	}
+	for i := 0; i < max(len(liveRanked), len(rebuiltRanked)); i++ {
+		var liveUser, rebuiltUser string
+		if i < len(liveRanked) {
+			liveUser = liveRanked[i]
+		}
+		if i < len(rebuiltRanked) {
+			rebuiltUser = rebuiltRanked[i]
+		}
+		if liveUser != rebuiltUser {
+			return fmt.Sprintf("score:rank position %d: live %q, rebuilt %q", i+1, liveUser, rebuiltUser), nil
+		}
+	}
+	return "", nil
+}
+
+// heldAt returns the ms the users held fields up to at like holdTimes
+func heldAt(client *redis.Client, users []string, counts []float64, at int64) ([]int64, error) {
+	var held, since *redis.FloatSliceCmd
+	if _, err := client.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		held = pipe.ZMScore(context.Background(), "score:holdTime", users...)
+		since = pipe.ZMScore(context.Background(), "score:holdTime:since", users...)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	result := make([]int64, len(users))
+	for i := range users {
+		result[i] = int64(held.Val()[i])
+		if settled := int64(since.Val()[i]); settled > 0 && at > settled {
+			result[i] += int64(counts[i]) * (at - settled)
+		}
+	}
+	return result, nil
+}
+
+// readHash scans a whole hash to avoid blocking redis on large maps
+func readHash(client *redis.Client, key string) (map[string]string, error) {
+	ctx := context.Background()
+	result := make(map[string]string)
+	iter := client.HScan(ctx, key, 0, "", 10000).Iterator()
+	for iter.Next(ctx) {
+		field := iter.Val()
+		if !iter.Next(ctx) {
+			break
+		}
+		result[field] = iter.Val()
+	}
+	return result, iter.Err()
+}
+
+// readBitmap reads a bitmap without trailing zero bytes, a missing bitmap is
+// the same as an empty one
+func readBitmap(client *redis.Client, key string) ([]byte, error) {
+	bitmap, err := client.Get(context.Background(), key).Bytes()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	return bytes.TrimRight(bitmap, "\x00"), nil
+}
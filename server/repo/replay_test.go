@@ -0,0 +1,104 @@
+package repo_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/zodius/api-war/model"
+	"github.com/zodius/api-war/repo"
+	"github.com/zodius/api-war/repo/repotest"
+)
+
+func newClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{
+		Addr: server.Addr(),
+	})
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func TestReplay(t *testing.T) {
+	repotest.RegisterProtocols()
+	conquerType := model.Protocols()[0].Name
+
+	_, live := newClient(t)
+	r := repo.NewRepo(live, model.MapConfig{
+		FieldCount: model.DefaultFieldCount,
+		BatchSize:  model.DefaultBatchSize,
+	})
+	for _, username := range []string{"alice", "bob", "carol"} {
+		if err := r.CreateUser(username, "hash"); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+	mustConquer := func(fieldID int, username string) {
+		t.Helper()
+		if _, err := r.Conquer(fieldID, conquerType, username, model.FieldLock{}); err != nil {
+			t.Fatalf("Conquer: %v", err)
+		}
+	}
+
+	// a finished round is reset by the archive
+	mustConquer(1, "carol")
+	if err := r.ArchiveRound(1); err != nil {
+		t.Fatalf("ArchiveRound: %v", err)
+	}
+	mustConquer(1, "alice")
+	mustConquer(2, "alice")
+	mustConquer(3, "alice")
+	mustConquer(1, "bob")
+	mustConquer(9, "carol")
+	// held time is settled on every change of a count
+	time.Sleep(5 * time.Millisecond)
+	if _, err := r.SetFieldOwner(2, conquerType, "carol"); err != nil {
+		t.Fatalf("SetFieldOwner: %v", err)
+	}
+	if _, err := r.SetFieldOwner(3, conquerType, ""); err != nil {
+		t.Fatalf("SetFieldOwner: %v", err)
+	}
+	if _, err := r.WipeUserFields("carol"); err != nil {
+		t.Fatalf("WipeUserFields: %v", err)
+	}
+	mustConquer(20, "bob")
+	// alice and carol tie, alice got there first
+	mustConquer(21, "alice")
+	time.Sleep(2 * time.Millisecond)
+	mustConquer(22, "carol")
+
+	targetServer, target := newClient(t)
+	events, err := repo.Replay(live, target)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if events != 13 {
+		t.Errorf("replayed %d events, want 13", events)
+	}
+	differences, err := repo.Verify(live, target)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(differences) != 0 {
+		t.Errorf("differences after replay = %v", differences)
+	}
+
+	// a lost write shows up in the verification
+	targetServer.HSet("fields:"+conquerType+":conquerer", "20", "alice")
+	targetServer.ZAdd("score:conquerCount", 1, "bob")
+	targetServer.ZAdd("score:rank", 1<<40, "carol")
+	targetServer.ZAdd("score:holdTime", 1, "alice")
+	differences, err = repo.Verify(live, target)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(differences) != 4 {
+		t.Errorf("differences = %v, want the owner, the count of bob, the rank and the held time of alice", differences)
+	}
+
+	if _, err := repo.Replay(live, target); !errors.Is(err, repo.ErrTargetNotEmpty) {
+		t.Errorf("Replay into a used keyspace = %v, want ErrTargetNotEmpty", err)
+	}
+}
@@ -6,29 +6,44 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"slices"
+	"sort"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/zodius/api-war/model"
 )
 
+// tokenTTL is how long a token stays valid after its last use
+const tokenTTL = 15 * time.Minute
+
 type repo struct {
-	client *redis.Client
-	lock   *sync.Mutex
+	client    *redis.Client
+	batchSize int
+	now       func() time.Time
+	holdRanks *holdRankCache
+}
+
+func NewRepo(client *redis.Client, mapConfig model.MapConfig) model.Repo {
+	return NewRepoWithClock(client, mapConfig, time.Now)
 }
 
-func NewRepo(client *redis.Client) model.Repo {
+// NewRepoWithClock returns a repo which reads the current time from now,
+// tests use it with a fast forwarded redis to expire tokens without waiting
+func NewRepoWithClock(client *redis.Client, mapConfig model.MapConfig, now func() time.Time) model.Repo {
 	return &repo{
-		client: client,
-		lock:   new(sync.Mutex),
+		client:    client,
+		batchSize: mapConfig.BatchSize,
+		now:       now,
+		holdRanks: &holdRankCache{rankings: make(map[string]holdRanking)},
 	}
 }
 
 func (r *repo) GetUser(username string) (model.User, error) {
 	values, err := r.client.HMGet(context.Background(), fmt.Sprintf("user:%s", username),
-		"password", "id",
+		"password", "id", "role", "banned", "team",
 	).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
@@ -52,34 +67,32 @@ func (r *repo) GetUser(username string) (model.User, error) {
 		return model.User{}, err
 	}
 
+	role, _ := values[2].(string)
+	banned, _ := values[3].(string)
+	teamID := 0
+	if team, ok := values[4].(string); ok {
+		if teamID, err = strconv.Atoi(team); err != nil {
+			return model.User{}, err
+		}
+	}
+
 	return model.User{
 		Username: username,
 		Password: password,
 		ID:       id,
+		Role:     role,
+		Banned:   banned == "1",
+		TeamID:   teamID,
 	}, nil
 }
 
 func (r *repo) CreateUser(username, password string) error {
-	// create user requires lock to prevent race condition in user count
-	r.lock.Lock()
-	defer r.lock.Unlock()
-	// get user count as id
-	userCount, err := r.client.Get(context.Background(), "usercount").Int()
+	// incr is atomic across backends, so every user gets a unique id
+	userID, err := r.client.Incr(context.Background(), "usercount").Result()
 	if err != nil {
-		// if not exist, set 0
-		if errors.Is(err, redis.Nil) {
-			userCount = 0
-			err = r.client.Set(context.Background(), "usercount", 0, 0).Err()
-			if err != nil {
-				return err
-			}
-		} else {
-			return err
-		}
+		return err
 	}
 
-	userID := userCount + 1
-
 	// create user
 	if err := r.client.HSet(context.Background(), fmt.Sprintf("user:%s", username),
 		"password", password,
@@ -88,11 +101,6 @@ func (r *repo) CreateUser(username, password string) error {
 		return err
 	}
 
-	// increment user count
-	if err := r.client.Incr(context.Background(), "usercount").Err(); err != nil {
-		return err
-	}
-
 	// create score
 	if err := r.client.ZAdd(context.Background(), "score:conquerCount", redis.Z{
 		Score:  0,
@@ -100,9 +108,12 @@ func (r *repo) CreateUser(username, password string) error {
 	}).Err(); err != nil {
 		return err
 	}
+	if err := updateRankScript.Run(context.Background(), r.client, nil, username).Err(); err != nil {
+		return err
+	}
 
-	conquerTypes := []string{"restful", "graphql"}
-	for _, conquerType := range conquerTypes {
+	for _, protocol := range model.Protocols() {
+		conquerType := protocol.Name
 		// create conquer history score
 		if err := r.client.ZAdd(context.Background(), fmt.Sprintf("score:conquerHistory:%s", conquerType), redis.Z{
 			Score:  0,
@@ -128,21 +139,39 @@ func (r *repo) CreateUser(username, password string) error {
 	return nil
 }
 
+func (r *repo) SetPassword(username, password string) error {
+	userKey := fmt.Sprintf("user:%s", username)
+	exists, err := r.client.Exists(context.Background(), userKey).Result()
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return model.ErrNotFound
+	}
+	return r.client.HSet(context.Background(), userKey, "password", password).Err()
+}
+
 func (r *repo) CreateToken(username string) (token string, err error) {
 	token, err = randomToken()
 	if err != nil {
 		return "", err
 	}
 
-	err = r.client.Set(context.Background(), fmt.Sprintf("token:%s", token), username, 15*time.Minute).Err()
-	if err != nil {
+	indexKey := fmt.Sprintf("user:%s:tokens", username)
+	if err := createTokenScript.Run(context.Background(), r.client,
+		[]string{fmt.Sprintf("token:%s", token), indexKey, indexKey + ":expiry"},
+		tokenTTL.Milliseconds(), token, username, r.now().UnixMilli(),
+	).Err(); err != nil {
 		return "", err
 	}
 	return token, nil
 }
 
 func (r *repo) GetTokenUsername(token string) (username string, err error) {
-	username, err = r.client.Get(context.Background(), fmt.Sprintf("token:%s", token)).Result()
+	username, err = touchTokenScript.Run(context.Background(), r.client,
+		[]string{fmt.Sprintf("token:%s", token)},
+		tokenTTL.Milliseconds(), token, r.now().UnixMilli(),
+	).Text()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			return "", model.ErrNotFound
@@ -152,22 +181,113 @@ func (r *repo) GetTokenUsername(token string) (username string, err error) {
 	return username, nil
 }
 
+func (r *repo) DeleteToken(token string) error {
+	return deleteTokenScript.Run(context.Background(), r.client,
+		[]string{fmt.Sprintf("token:%s", token)},
+		token,
+	).Err()
+}
+
+func (r *repo) DeleteUserTokens(username string) error {
+	indexKey := fmt.Sprintf("user:%s:tokens", username)
+	return deleteUserTokensScript.Run(context.Background(), r.client,
+		[]string{indexKey, indexKey + ":expiry"},
+	).Err()
+}
+
+func (r *repo) GetUserTokens(username string) ([]model.Session, error) {
+	indexKey := fmt.Sprintf("user:%s:tokens", username)
+	index, err := r.client.HGetAll(context.Background(), indexKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make(map[string]*model.Session)
+	for field, value := range index {
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		var token string
+		var created bool
+		if prefix, ok := strings.CutSuffix(field, ":created"); ok {
+			token, created = prefix, true
+		} else if prefix, ok := strings.CutSuffix(field, ":lastUsed"); ok {
+			token = prefix
+		} else {
+			continue
+		}
+
+		session, ok := sessions[token]
+		if !ok {
+			session = &model.Session{Token: token}
+			sessions[token] = session
+		}
+		if created {
+			session.CreatedAt = time.UnixMilli(ms)
+		} else {
+			session.LastUsedAt = time.UnixMilli(ms)
+		}
+	}
+
+	// drop expired tokens from the index
+	tokens := make([]string, 0, len(sessions))
+	for token := range sessions {
+		tokens = append(tokens, token)
+	}
+	exists := make([]*redis.IntCmd, len(tokens))
+	if _, err := r.client.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		for i, token := range tokens {
+			exists[i] = pipe.Exists(context.Background(), fmt.Sprintf("token:%s", token))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	result := make([]model.Session, 0, len(tokens))
+	expired := make([]string, 0)
+	expiredTokens := make([]interface{}, 0)
+	for i, token := range tokens {
+		if exists[i].Val() == 0 {
+			expired = append(expired, token+":created", token+":lastUsed")
+			expiredTokens = append(expiredTokens, token)
+			continue
+		}
+		result = append(result, *sessions[token])
+	}
+	if len(expired) > 0 {
+		if _, err := r.client.TxPipelined(context.Background(), func(pipe redis.Pipeliner) error {
+			pipe.HDel(context.Background(), indexKey, expired...)
+			pipe.ZRem(context.Background(), indexKey+":expiry", expiredTokens...)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
 func (r *repo) GetMap(startInput, endInput int) (model.Map, error) {
 	mapMap := make(map[int]model.Field, endInput-startInput+1)
 
-	conquerTypes := []string{"restful", "graphql"}
-	for _, conquerType := range conquerTypes {
+	for _, protocol := range model.Protocols() {
+		conquerType := protocol.Name
 		start, end := startInput, endInput
 		// get conquerer within range in batch
 		// for each batch, get conquerer using hmget
 		for start <= end {
 			// check remaining is less than batchsize
-			batchSize := model.BatchSize
+			batchSize := r.batchSize
 			if start+batchSize > end {
 				batchSize = end - start + 1
 			}
 			fields := make([]string, 0, batchSize)
-			for i := start; i < start+batchSize-1; i++ {
+			for i := start; i < start+batchSize; i++ {
 				fields = append(fields, strconv.Itoa(i))
 			}
 			conquerers, err := r.client.HMGet(context.Background(), fmt.Sprintf("fields:%s:conquerer", conquerType),
@@ -200,15 +320,62 @@ func (r *repo) GetMap(startInput, endInput int) (model.Map, error) {
 		}
 	}
 
+	// keep fields in id order
 	fields := make([]model.Field, 0, len(mapMap))
-	for _, field := range mapMap {
-		fields = append(fields, field)
+	for fieldID := startInput; fieldID <= endInput; fieldID++ {
+		if field, ok := mapMap[fieldID]; ok {
+			fields = append(fields, field)
+		}
 	}
 	return model.Map{
 		Fields: fields,
 	}, nil
 }
 
+func (r *repo) GetFields(fieldIDs []int) (model.Map, error) {
+	protocols := model.Protocols()
+	fields := make([]model.Field, len(fieldIDs))
+	for i, fieldID := range fieldIDs {
+		fields[i] = model.Field{
+			FieldID:   fieldID,
+			Conquerer: make([]model.Owner, 0, len(protocols)),
+		}
+	}
+
+	// one round trip, hmget in batches like GetMap
+	cmds := make([][]*redis.SliceCmd, len(protocols))
+	if _, err := r.client.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		for i, protocol := range protocols {
+			for start := 0; start < len(fieldIDs); start += r.batchSize {
+				batch := make([]string, 0, r.batchSize)
+				for _, fieldID := range fieldIDs[start:min(start+r.batchSize, len(fieldIDs))] {
+					batch = append(batch, strconv.Itoa(fieldID))
+				}
+				cmds[i] = append(cmds[i], pipe.HMGet(context.Background(),
+					fmt.Sprintf("fields:%s:conquerer", protocol.Name), batch...))
+			}
+		}
+		return nil
+	}); err != nil {
+		return model.Map{}, err
+	}
+
+	for i, protocol := range protocols {
+		index := 0
+		for _, cmd := range cmds[i] {
+			for _, conquerer := range cmd.Val() {
+				owner, _ := conquerer.(string)
+				fields[index].Conquerer = append(fields[index].Conquerer, model.Owner{
+					ConquerType: protocol.Name,
+					Owner:       owner,
+				})
+				index++
+			}
+		}
+	}
+	return model.Map{Fields: fields}, nil
+}
+
 func (r *repo) GetUserList() ([]model.User, error) {
 	users := make([]model.User, 0)
 	// get all users
@@ -226,130 +393,234 @@ func (r *repo) GetUserList() ([]model.User, error) {
 }
 
 func (r *repo) GetUserConquerField(username string, conquerType string) ([]int, error) {
-	fieldCount := model.FieldCount
-	batchSize := model.BatchSize
+	// the whole bitmap is at most FieldCount/8 bytes, fetch it at once
+	bitmap, err := r.client.Get(context.Background(), fmt.Sprintf("user:%s:conquerField:%s", username, conquerType)).Bytes()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
 
 	result := make([]int, 0)
-
-	// get bitmap in batch
-	for i := 0; i < fieldCount; i += batchSize {
-		ids := make([]int, 0)
-		// calculate start and end
-		start := i
-		end := i + batchSize - 1
-		// count bit within range
-		count, err := r.client.BitCount(context.Background(), fmt.Sprintf("user:%s:conquerField:%s", username, conquerType), &redis.BitCount{
-			Start: int64(start),
-			End:   int64(end),
-		}).Result()
-		if err != nil {
-			return nil, err
-		}
-		if count == 0 {
+	for i, b := range bitmap {
+		if b == 0 {
 			continue
 		}
-
-		// get bitpos in batch
-		for start <= end {
-			pos, err := r.client.BitPos(context.Background(), fmt.Sprintf("user:%s:conquerField:%s", username, conquerType),
-				1,
-				int64(start), int64(end),
-			).Result()
-			if err != nil {
-				return nil, err
+		// redis bitmaps are big endian within a byte
+		for bit := 0; bit < 8; bit++ {
+			if b&(0x80>>bit) != 0 {
+				result = append(result, i*8+bit)
 			}
-			if pos == -1 {
-				break
-			}
-			ids = append(ids, int(pos))
-			start = int(pos) + 1
 		}
+	}
+	return result, nil
+}
 
-		result = append(result, ids...)
+func (r *repo) GetFieldHistory(fieldID int) ([]model.FieldChange, error) {
+	entries, err := r.client.LRange(context.Background(), fmt.Sprintf("field:%d:history", fieldID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]model.FieldChange, 0, len(entries))
+	for _, entry := range entries {
+		// <unix ms>:<type>:<owner>, usernames may contain colons
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid field history entry %q", entry)
+		}
+		ms, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid field history entry %q: %w", entry, err)
+		}
+		result = append(result, model.FieldChange{
+			Time:        time.UnixMilli(ms),
+			ConquerType: parts[1],
+			Owner:       parts[2],
+		})
 	}
 	return result, nil
 }
 
-func (r *repo) GetScoreboard() ([]model.Score, error) {
-	// make hashmap for calculate
-	scoreMap := make(map[string]model.Score)
+func (r *repo) GetScoreboard(offset, limit int) ([]model.Score, error) {
+	return r.scoreboard("", offset, limit)
+}
+
+func (r *repo) GetScoreNeighbourhood(username string, around int) ([]model.Score, error) {
+	return r.scoreNeighbourhood("", username, around)
+}
 
-	zrangeKey := []string{
-		"restful",
-		"graphql",
+// scoreboard reads the score zsets under prefix, the live ones without prefix
+func (r *repo) scoreboard(prefix string, offset, limit int) ([]model.Score, error) {
+	scoring, endAt, err := r.scoringRound(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if scoring == model.ScoringHoldTime {
+		// held time changes every second, so the order is computed on read
+		usernames, held, err := r.holdRanked(prefix, endAt)
+		if err != nil {
+			return nil, err
+		}
+		start, end := min(offset, len(usernames)), min(offset+limit, len(usernames))
+		return r.scores(prefix, start, usernames[start:end], held[start:end])
 	}
 
-	// get first 100 conquerCount
-	zrange, err := r.client.ZRangeWithScores(context.Background(), "score:conquerCount", -100, -1).Result()
+	usernames, err := r.client.ZRevRange(context.Background(), prefix+"score:rank",
+		int64(offset), int64(offset+limit-1),
+	).Result()
 	if err != nil {
 		return nil, err
 	}
+	held, err := r.holdTimes(prefix, usernames, endAt)
+	if err != nil {
+		return nil, err
+	}
+	return r.scores(prefix, offset, usernames, held)
+}
 
-	userKeyList := make([]string, 0, len(zrange))
-	for _, z := range zrange {
-		scoreMap[z.Member.(string)] = model.Score{
-			Username:            z.Member.(string),
-			ConquerFieldCount:   int(z.Score),
-			ConquerHistoryCount: make(map[string]int),
+// scoreNeighbourhood reads the scores around the rank of a user under
+// prefix, hold time ranks come from a single ranking
+func (r *repo) scoreNeighbourhood(prefix, username string, around int) ([]model.Score, error) {
+	scoring, endAt, err := r.scoringRound(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if scoring == model.ScoringHoldTime {
+		usernames, held, err := r.holdRanked(prefix, endAt)
+		if err != nil {
+			return nil, err
+		}
+		index := slices.Index(usernames, username)
+		if index < 0 {
+			return nil, model.ErrNotFound
 		}
-		userKeyList = append(userKeyList, z.Member.(string))
+		start, end := max(index-around, 0), min(index+around+1, len(usernames))
+		return r.scores(prefix, start, usernames[start:end], held[start:end])
+	}
+
+	index, err := r.client.ZRevRank(context.Background(), prefix+"score:rank", username).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, model.ErrNotFound
+		}
+		return nil, err
+	}
+	start := max(int(index)-around, 0)
+	usernames, err := r.client.ZRevRange(context.Background(), prefix+"score:rank",
+		int64(start), index+int64(around),
+	).Result()
+	if err != nil {
+		return nil, err
+	}
+	held, err := r.holdTimes(prefix, usernames, endAt)
+	if err != nil {
+		return nil, err
+	}
+	return r.scores(prefix, start, usernames, held)
+}
+
+// scores reads the scores of usernames ranked from offset on under prefix
+func (r *repo) scores(prefix string, offset int, usernames []string, held []int64) ([]model.Score, error) {
+	scoreList := make([]model.Score, 0, len(usernames))
+	if len(usernames) == 0 {
+		return scoreList, nil
+	}
+
+	counts, err := r.client.ZMScore(context.Background(), prefix+"score:conquerCount", usernames...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, username := range usernames {
+		scoreList = append(scoreList, model.Score{
+			Rank:                offset + i + 1,
+			Username:            username,
+			ConquerFieldCount:   int(counts[i]),
+			ConquerHistoryCount: make(map[string]int),
+			HoldPoints:          int(held[i] / 1000),
+		})
 	}
 
 	// get conquerHistory
-	for _, key := range zrangeKey {
+	for _, protocol := range model.Protocols() {
 		values, err := r.client.ZMScore(context.Background(),
-			fmt.Sprintf("score:conquerHistory:%s", key),
-			userKeyList...,
+			fmt.Sprintf("%sscore:conquerHistory:%s", prefix, protocol.Name),
+			usernames...,
 		).Result()
 		if err != nil {
 			return nil, err
 		}
 		for i, value := range values {
-			scoreMap[userKeyList[i]].ConquerHistoryCount[key] = int(value)
+			scoreList[i].ConquerHistoryCount[protocol.ScoreColumn] = int(value)
 		}
 	}
-
-	// convert map to slice
-	var scoreList []model.Score
-	for _, score := range scoreMap {
-		scoreList = append(scoreList, score)
-	}
 	return scoreList, nil
 }
 
-func (r *repo) SetFieldConquerer(fieldID int, conquerType, username string) error {
-	// set bit in user bitmap
-	if err := r.client.SetBit(context.Background(), fmt.Sprintf("user:%s:conquerField:%s", username, conquerType), int64(fieldID), 1).Err(); err != nil {
-		return err
-	}
-	// set conquerer in field map
-	if err := r.client.HSet(context.Background(),
-		fmt.Sprintf("fields:%s:conquerer", conquerType),
-		fieldID, username,
-	).Err(); err != nil {
-		return err
-	}
-	return nil
+func (r *repo) Conquer(fieldID int, conquerType, username string, lock model.FieldLock) (string, error) {
+	keys, args := r.conquerCall(fieldID, conquerType, username, lock)
+	return conquerResult(conquerScript.Run(context.Background(), r.client, keys, args...).Result())
 }
 
-func (r *repo) AddScore(username string, fieldID int, conquerType string) error {
-	// add score:conquerCount
-	if err := r.client.ZIncrBy(context.Background(), "score:conquerCount", 1, username).Err(); err != nil {
-		return err
+// ConquerFields runs the conquer script for every field in one pipeline
+func (r *repo) ConquerFields(fieldIDs []int, conquerType, username string, lock model.FieldLock) ([]model.ConquerOutcome, error) {
+	// EvalSha in a pipeline can't fall back to Eval, so load the script first
+	if err := conquerScript.Load(context.Background(), r.client).Err(); err != nil {
+		return nil, err
 	}
-	// add score:conquerHistory:<conquerType>
-	switch conquerType {
-	case "restful":
-		if err := r.client.ZIncrBy(context.Background(), "score:conquerHistory:restful", 1, username).Err(); err != nil {
-			return err
+	cmds := make([]*redis.Cmd, len(fieldIDs))
+	if _, err := r.client.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		for i, fieldID := range fieldIDs {
+			keys, args := r.conquerCall(fieldID, conquerType, username, lock)
+			cmds[i] = conquerScript.EvalSha(context.Background(), pipe, keys, args...)
 		}
-	case "graphql":
-		if err := r.client.ZIncrBy(context.Background(), "score:conquerHistory:graphql", 1, username).Err(); err != nil {
-			return err
+		return nil
+	}); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	outcomes := make([]model.ConquerOutcome, len(fieldIDs))
+	for i, cmd := range cmds {
+		previous, err := conquerResult(cmd.Result())
+		if err != nil && !errors.Is(err, model.ErrTeammateField) && !errors.Is(err, model.ErrFieldLocked) {
+			return nil, err
 		}
+		outcomes[i] = model.ConquerOutcome{PreviousOwner: previous, Err: err}
 	}
+	return outcomes, nil
+}
 
-	return nil
+// conquerCall returns the keys and arguments of conquerScript
+func (r *repo) conquerCall(fieldID int, conquerType, username string, lock model.FieldLock) ([]string, []interface{}) {
+	keys := []string{
+		fmt.Sprintf("fields:%s:conquerer", conquerType),
+		fmt.Sprintf("user:%s:conquerField:%s", username, conquerType),
+		"score:conquerCount",
+		fmt.Sprintf("score:conquerHistory:%s", conquerType),
+		"map:version",
+		eventLogKey,
+		fmt.Sprintf("field:%s:%d:lock", conquerType, fieldID),
+		"rounds:archived",
+	}
+	args := []interface{}{
+		fieldID, username, conquerType, r.now().UnixMilli(),
+		lock.Duration.Milliseconds(), lock.For("").Milliseconds(),
+	}
+	return keys, args
+}
+
+// conquerResult reads the reply of conquerScript
+func conquerResult(result interface{}, err error) (string, error) {
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", model.ErrTeammateField
+		}
+		return "", err
+	}
+	switch result := result.(type) {
+	case string:
+		return result, nil
+	case int64:
+		return "", &model.FieldLockedError{UnlockAt: time.UnixMilli(result)}
+	}
+	return "", fmt.Errorf("unexpected conquer result %v", result)
 }
 
 func randomToken() (string, error) {
@@ -0,0 +1,50 @@
+package repo_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/zodius/api-war/model"
+	"github.com/zodius/api-war/repo"
+	"github.com/zodius/api-war/repo/repotest"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Harness {
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{
+			Addr: server.Addr(),
+		})
+		t.Cleanup(func() { client.Close() })
+
+		// the repo clock moves with the key expiry of miniredis
+		var lock sync.Mutex
+		now := time.Now()
+		clock := func() time.Time {
+			lock.Lock()
+			defer lock.Unlock()
+			return now
+		}
+
+		return repotest.Harness{
+			Repo: repo.NewRepoWithClock(client, model.MapConfig{
+				FieldCount: model.DefaultFieldCount,
+				BatchSize:  model.DefaultBatchSize,
+			}, clock),
+			Advance: func(d time.Duration) {
+				lock.Lock()
+				defer lock.Unlock()
+				now = now.Add(d)
+				server.FastForward(d)
+			},
+			TokenIndexSize: func(username string) int {
+				// every token has a created and a lastUsed entry
+				return int(client.HLen(context.Background(), "user:"+username+":tokens").Val() / 2)
+			},
+		}
+	})
+}
@@ -0,0 +1,1629 @@
+// Package repotest is a conformance suite every model.Repo implementation
+// is expected to pass, so handlers and services behave the same on any store.
+package repotest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zodius/api-war/model"
+)
+
+// Harness is a fresh, empty repo under test
+type Harness struct {
+	Repo model.Repo
+	// Advance moves the clock of the repo forward, used to expire tokens
+	Advance func(d time.Duration)
+	// TokenIndexSize returns how many tokens the session index of a user
+	// holds, expired ones included
+	TokenIndexSize func(username string) int
+}
+
+// Factory returns a new harness for every subtest
+type Factory func(t *testing.T) Harness
+
+var registerOnce sync.Once
+
+// RegisterProtocols registers two protocols unless the test binary already
+// imported real ones
+func RegisterProtocols() {
+	registerOnce.Do(func() {
+		if len(model.Protocols()) > 0 {
+			return
+		}
+		model.RegisterProtocol(model.Protocol{Name: "restful"})
+		model.RegisterProtocol(model.Protocol{Name: "graphql"})
+	})
+}
+
+// Run runs the conformance suite against the repos returned by newHarness
+func Run(t *testing.T, newHarness Factory) {
+	RegisterProtocols()
+
+	tests := []struct {
+		name string
+		test func(t *testing.T, h Harness)
+	}{
+		{"CreateUser", testCreateUser},
+		{"UniqueUserID", testUniqueUserID},
+		{"SetPassword", testSetPassword},
+		{"Token", testToken},
+		{"TokenExpiry", testTokenExpiry},
+		{"TokenSliding", testTokenSliding},
+		{"DeleteToken", testDeleteToken},
+		{"UserTokens", testUserTokens},
+		{"TokenIndexPrune", testTokenIndexPrune},
+		{"GetMapBatchBoundary", testGetMapBatchBoundary},
+		{"GetMapSingleField", testGetMapSingleField},
+		{"GetFields", testGetFields},
+		{"UserConquerField", testUserConquerField},
+		{"ConquerMovesOwnership", testConquerMovesOwnership},
+		{"ConquerOwnField", testConquerOwnField},
+		{"ScoreboardOrder", testScoreboardOrder},
+		{"ScoreboardTies", testScoreboardTies},
+		{"Round", testRound},
+		{"FreezeScoreboard", testFreezeScoreboard},
+		{"ArchiveRound", testArchiveRound},
+		{"RoleAndBan", testRoleAndBan},
+		{"UserStats", testUserStats},
+		{"SetFieldOwner", testSetFieldOwner},
+		{"WipeUserFields", testWipeUserFields},
+		{"AuditLog", testAuditLog},
+		{"MapVersion", testMapVersion},
+		{"MapSnapshot", testMapSnapshot},
+		{"MapChanges", testMapChanges},
+		{"FieldHistory", testFieldHistory},
+		{"FieldHistoryLimit", testFieldHistoryLimit},
+		{"Teams", testTeams},
+		{"TeamFull", testTeamFull},
+		{"TeammateConquer", testTeammateConquer},
+		{"TeamScores", testTeamScores},
+		{"FieldLock", testFieldLock},
+		{"HoldTime", testHoldTime},
+		{"ConquerFields", testConquerFields},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newHarness(t))
+		})
+	}
+}
+
+func createUsers(t *testing.T, repo model.Repo, usernames ...string) {
+	t.Helper()
+	for _, username := range usernames {
+		if err := repo.CreateUser(username, "password-"+username); err != nil {
+			t.Fatalf("CreateUser(%q): %v", username, err)
+		}
+	}
+}
+
+func conquer(t *testing.T, repo model.Repo, fieldID int, conquerType, username string) string {
+	t.Helper()
+	previous, err := repo.Conquer(fieldID, conquerType, username, model.FieldLock{})
+	if err != nil {
+		t.Fatalf("Conquer(%d, %q, %q): %v", fieldID, conquerType, username, err)
+	}
+	return previous
+}
+
+func testCreateUser(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob")
+
+	user, err := h.Repo.GetUser("alice")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if user.ID != 1 || user.Username != "alice" || user.Password != "password-alice" {
+		t.Errorf("GetUser(alice) = %+v", user)
+	}
+
+	if _, err := h.Repo.GetUser("nobody"); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("GetUser(nobody) error = %v, want ErrNotFound", err)
+	}
+
+	users, err := h.Repo.GetUserList()
+	if err != nil {
+		t.Fatalf("GetUserList: %v", err)
+	}
+	want := []model.User{{ID: 1, Username: "alice"}, {ID: 2, Username: "bob"}}
+	if !reflect.DeepEqual(users, want) {
+		t.Errorf("GetUserList = %+v, want %+v", users, want)
+	}
+}
+
+func testUniqueUserID(t *testing.T, h Harness) {
+	const count = 50
+
+	var wg sync.WaitGroup
+	errs := make(chan error, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- h.Repo.CreateUser(fmt.Sprintf("user%d", i), "password")
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+
+	seen := make(map[int]string, count)
+	for i := 0; i < count; i++ {
+		username := fmt.Sprintf("user%d", i)
+		user, err := h.Repo.GetUser(username)
+		if err != nil {
+			t.Fatalf("GetUser(%q): %v", username, err)
+		}
+		if other, ok := seen[user.ID]; ok {
+			t.Errorf("%s and %s share id %d", other, username, user.ID)
+		}
+		if user.ID < 1 || user.ID > count {
+			t.Errorf("%s got id %d, want 1..%d", username, user.ID, count)
+		}
+		seen[user.ID] = username
+	}
+}
+
+func testSetPassword(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice")
+
+	if err := h.Repo.SetPassword("alice", "new-password"); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+	user, err := h.Repo.GetUser("alice")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if user.Password != "new-password" || user.ID != 1 {
+		t.Errorf("GetUser after SetPassword = %+v", user)
+	}
+
+	if err := h.Repo.SetPassword("nobody", "password"); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("SetPassword(nobody) error = %v, want ErrNotFound", err)
+	}
+	if _, err := h.Repo.GetUser("nobody"); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("SetPassword created user nobody: %v", err)
+	}
+}
+
+func testToken(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice")
+
+	token, err := h.Repo.CreateToken("alice")
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	other, err := h.Repo.CreateToken("alice")
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	if token == other {
+		t.Errorf("CreateToken returned %q twice", token)
+	}
+
+	username, err := h.Repo.GetTokenUsername(token)
+	if err != nil || username != "alice" {
+		t.Errorf("GetTokenUsername = %q, %v, want alice", username, err)
+	}
+	if _, err := h.Repo.GetTokenUsername("invalid"); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("GetTokenUsername(invalid) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testTokenExpiry(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice")
+
+	token, err := h.Repo.CreateToken("alice")
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+
+	h.Advance(16 * time.Minute)
+	if _, err := h.Repo.GetTokenUsername(token); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("GetTokenUsername after 16 minutes error = %v, want ErrNotFound", err)
+	}
+}
+
+func testTokenSliding(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice")
+
+	token, err := h.Repo.CreateToken("alice")
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+
+	// every use extends the token lifetime
+	for i := 0; i < 3; i++ {
+		h.Advance(10 * time.Minute)
+		if _, err := h.Repo.GetTokenUsername(token); err != nil {
+			t.Fatalf("token expired after %d uses: %v", i, err)
+		}
+	}
+
+	h.Advance(16 * time.Minute)
+	if _, err := h.Repo.GetTokenUsername(token); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("GetTokenUsername after 16 idle minutes error = %v, want ErrNotFound", err)
+	}
+}
+
+func testDeleteToken(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob")
+
+	first, _ := h.Repo.CreateToken("alice")
+	second, _ := h.Repo.CreateToken("alice")
+	other, _ := h.Repo.CreateToken("bob")
+
+	if err := h.Repo.DeleteToken(first); err != nil {
+		t.Fatalf("DeleteToken: %v", err)
+	}
+	if _, err := h.Repo.GetTokenUsername(first); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("deleted token still valid: %v", err)
+	}
+	if _, err := h.Repo.GetTokenUsername(second); err != nil {
+		t.Errorf("DeleteToken revoked another token: %v", err)
+	}
+	if err := h.Repo.DeleteToken("invalid"); err != nil {
+		t.Errorf("DeleteToken(invalid): %v", err)
+	}
+
+	if err := h.Repo.DeleteUserTokens("alice"); err != nil {
+		t.Fatalf("DeleteUserTokens: %v", err)
+	}
+	if _, err := h.Repo.GetTokenUsername(second); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("DeleteUserTokens left a token valid: %v", err)
+	}
+	if _, err := h.Repo.GetTokenUsername(other); err != nil {
+		t.Errorf("DeleteUserTokens revoked another user's token: %v", err)
+	}
+}
+
+func testTokenIndexPrune(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice")
+
+	// a client logging in every 4 minutes without listing its sessions
+	for i := 0; i < 20; i++ {
+		if _, err := h.Repo.CreateToken("alice"); err != nil {
+			t.Fatalf("CreateToken: %v", err)
+		}
+		h.Advance(4 * time.Minute)
+	}
+	if _, err := h.Repo.CreateToken("alice"); err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	// tokens of the last 15 minutes, logged in 0, 4, 8 and 12 minutes ago
+	if size := h.TokenIndexSize("alice"); size != 4 {
+		t.Errorf("token index holds %d tokens after 21 logins, want 4", size)
+	}
+
+	// the index goes away with the last token
+	h.Advance(16 * time.Minute)
+	if _, err := h.Repo.CreateToken("alice"); err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	if size := h.TokenIndexSize("alice"); size != 1 {
+		t.Errorf("token index holds %d tokens after the others expired, want 1", size)
+	}
+}
+
+func testUserTokens(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice")
+
+	sessions, err := h.Repo.GetUserTokens("alice")
+	if err != nil {
+		t.Fatalf("GetUserTokens: %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("new user has sessions %+v", sessions)
+	}
+
+	expiring, _ := h.Repo.CreateToken("alice")
+	h.Advance(10 * time.Minute)
+	active, _ := h.Repo.CreateToken("alice")
+	revoked, _ := h.Repo.CreateToken("alice")
+	if err := h.Repo.DeleteToken(revoked); err != nil {
+		t.Fatalf("DeleteToken: %v", err)
+	}
+
+	sessions, err = h.Repo.GetUserTokens("alice")
+	if err != nil {
+		t.Fatalf("GetUserTokens: %v", err)
+	}
+	tokens := make(map[string]bool, len(sessions))
+	for _, session := range sessions {
+		tokens[session.Token] = true
+	}
+	if len(sessions) != 2 || !tokens[expiring] || !tokens[active] {
+		t.Fatalf("GetUserTokens = %+v, want both unrevoked tokens", sessions)
+	}
+	for _, session := range sessions {
+		if session.CreatedAt.IsZero() || session.LastUsedAt.Before(session.CreatedAt) {
+			t.Errorf("session times created=%v lastUsed=%v", session.CreatedAt, session.LastUsedAt)
+		}
+	}
+
+	h.Advance(6 * time.Minute)
+	sessions, err = h.Repo.GetUserTokens("alice")
+	if err != nil {
+		t.Fatalf("GetUserTokens: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Token != active {
+		t.Errorf("GetUserTokens after expiry = %+v, want only the active token", sessions)
+	}
+}
+
+func testGetMapBatchBoundary(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob")
+	restful, graphql := model.Protocols()[0].Name, model.Protocols()[1].Name
+
+	owned := map[int]string{
+		1:                            "alice",
+		model.DefaultBatchSize - 1:   "bob",
+		model.DefaultBatchSize:       "alice",
+		model.DefaultBatchSize + 1:   "bob",
+		2 * model.DefaultBatchSize:   "alice",
+		2*model.DefaultBatchSize + 1: "bob",
+	}
+	for fieldID, username := range owned {
+		conquer(t, h.Repo, fieldID, restful, username)
+	}
+	conquer(t, h.Repo, model.DefaultBatchSize, graphql, "bob")
+
+	start, end := 1, 2*model.DefaultBatchSize+1
+	m, err := h.Repo.GetMap(start, end)
+	if err != nil {
+		t.Fatalf("GetMap: %v", err)
+	}
+	if len(m.Fields) != end-start+1 {
+		t.Fatalf("GetMap(%d, %d) returned %d fields", start, end, len(m.Fields))
+	}
+	for i, field := range m.Fields {
+		if field.FieldID != start+i {
+			t.Fatalf("field %d has id %d, want fields in id order", i, field.FieldID)
+		}
+	}
+
+	represent := m.Representation().(map[int]map[string]string)
+	for fieldID := start; fieldID <= end; fieldID++ {
+		if got := represent[fieldID][restful]; got != owned[fieldID] {
+			t.Errorf("field %d %s owner = %q, want %q", fieldID, restful, got, owned[fieldID])
+		}
+	}
+	if got := represent[model.DefaultBatchSize][graphql]; got != "bob" {
+		t.Errorf("field %d %s owner = %q, want bob", model.DefaultBatchSize, graphql, got)
+	}
+}
+
+func testGetMapSingleField(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice")
+	restful := model.Protocols()[0].Name
+	conquer(t, h.Repo, model.DefaultBatchSize, restful, "alice")
+
+	m, err := h.Repo.GetMap(model.DefaultBatchSize, model.DefaultBatchSize)
+	if err != nil {
+		t.Fatalf("GetMap: %v", err)
+	}
+	if len(m.Fields) != 1 || m.Fields[0].FieldID != model.DefaultBatchSize {
+		t.Fatalf("GetMap single field = %+v", m.Fields)
+	}
+	if len(m.Fields[0].Conquerer) != len(model.Protocols()) {
+		t.Errorf("field has %d owners, want one per protocol", len(m.Fields[0].Conquerer))
+	}
+	represent := m.Representation().(map[int]map[string]string)
+	if got := represent[model.DefaultBatchSize][restful]; got != "alice" {
+		t.Errorf("owner = %q, want alice", got)
+	}
+}
+
+func testGetFields(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob")
+	restful, graphql := model.Protocols()[0].Name, model.Protocols()[1].Name
+	conquer(t, h.Repo, 3, restful, "alice")
+	conquer(t, h.Repo, 2*model.DefaultBatchSize+5, graphql, "bob")
+
+	// more ids than a batch, out of order and repeated
+	fieldIDs := []int{2*model.DefaultBatchSize + 5, 3}
+	for fieldID := 1; fieldID <= model.DefaultBatchSize; fieldID++ {
+		fieldIDs = append(fieldIDs, fieldID)
+	}
+	m, err := h.Repo.GetFields(fieldIDs)
+	if err != nil {
+		t.Fatalf("GetFields: %v", err)
+	}
+	if len(m.Fields) != len(fieldIDs) {
+		t.Fatalf("GetFields returned %d fields, want %d", len(m.Fields), len(fieldIDs))
+	}
+	for i, field := range m.Fields {
+		if field.FieldID != fieldIDs[i] || len(field.Conquerer) != len(model.Protocols()) {
+			t.Fatalf("field %d = %+v, want id %d with an owner per protocol", i, field, fieldIDs[i])
+		}
+	}
+	represent := m.Representation().(map[int]map[string]string)
+	if represent[3][restful] != "alice" || represent[2*model.DefaultBatchSize+5][graphql] != "bob" || represent[1][restful] != "" {
+		t.Errorf("GetFields owners = %v", represent)
+	}
+}
+
+func testUserConquerField(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice")
+	restful, graphql := model.Protocols()[0].Name, model.Protocols()[1].Name
+
+	fields, err := h.Repo.GetUserConquerField("alice", restful)
+	if err != nil {
+		t.Fatalf("GetUserConquerField: %v", err)
+	}
+	if len(fields) != 0 {
+		t.Errorf("new user owns %v", fields)
+	}
+
+	// neighbours within a byte, across bytes and across batches
+	want := []int{1, 7, 8, 9, 100, 200, model.DefaultBatchSize, 8 * model.DefaultBatchSize, model.DefaultFieldCount}
+	for i := len(want) - 1; i >= 0; i-- {
+		conquer(t, h.Repo, want[i], restful, "alice")
+	}
+	conquer(t, h.Repo, 42, graphql, "alice")
+
+	fields, err = h.Repo.GetUserConquerField("alice", restful)
+	if err != nil {
+		t.Fatalf("GetUserConquerField: %v", err)
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("GetUserConquerField = %v, want %v", fields, want)
+	}
+
+	fields, err = h.Repo.GetUserConquerField("alice", graphql)
+	if err != nil {
+		t.Fatalf("GetUserConquerField: %v", err)
+	}
+	if !reflect.DeepEqual(fields, []int{42}) {
+		t.Errorf("GetUserConquerField(%s) = %v, want [42]", graphql, fields)
+	}
+}
+
+func testConquerMovesOwnership(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob")
+	restful := model.Protocols()[0].Name
+
+	if previous := conquer(t, h.Repo, 5, restful, "alice"); previous != "" {
+		t.Errorf("previous owner of free field = %q", previous)
+	}
+	conquer(t, h.Repo, 6, restful, "alice")
+	if previous := conquer(t, h.Repo, 5, restful, "bob"); previous != "alice" {
+		t.Errorf("previous owner = %q, want alice", previous)
+	}
+
+	aliceFields, _ := h.Repo.GetUserConquerField("alice", restful)
+	if !reflect.DeepEqual(aliceFields, []int{6}) {
+		t.Errorf("alice fields = %v, want [6]", aliceFields)
+	}
+	bobFields, _ := h.Repo.GetUserConquerField("bob", restful)
+	if !reflect.DeepEqual(bobFields, []int{5}) {
+		t.Errorf("bob fields = %v, want [5]", bobFields)
+	}
+
+	scores := scoreMap(t, h.Repo)
+	if scores["alice"].ConquerFieldCount != 1 || scores["bob"].ConquerFieldCount != 1 {
+		t.Errorf("field counts alice=%d bob=%d, want 1 and 1",
+			scores["alice"].ConquerFieldCount, scores["bob"].ConquerFieldCount)
+	}
+	if got := scores["alice"].ConquerHistoryCount[restful]; got != 2 {
+		t.Errorf("alice history = %d, want 2", got)
+	}
+}
+
+func testConquerOwnField(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice")
+	restful := model.Protocols()[0].Name
+
+	conquer(t, h.Repo, 5, restful, "alice")
+	if previous := conquer(t, h.Repo, 5, restful, "alice"); previous != "alice" {
+		t.Errorf("previous owner = %q, want alice", previous)
+	}
+
+	score := scoreMap(t, h.Repo)["alice"]
+	if score.ConquerFieldCount != 1 || score.ConquerHistoryCount[restful] != 1 {
+		t.Errorf("re-conquer changed score: %+v", score)
+	}
+}
+
+func testScoreboardOrder(t *testing.T, h Harness) {
+	scoreList, err := h.Repo.GetScoreboard(0, 100)
+	if err != nil {
+		t.Fatalf("GetScoreboard on empty repo: %v", err)
+	}
+	if len(scoreList) != 0 {
+		t.Errorf("empty repo scoreboard = %+v", scoreList)
+	}
+
+	createUsers(t, h.Repo, "alice", "bob", "carol")
+	restful := model.Protocols()[0].Name
+	for fieldID := 1; fieldID <= 3; fieldID++ {
+		conquer(t, h.Repo, fieldID, restful, "bob")
+	}
+	conquer(t, h.Repo, 4, restful, "carol")
+
+	scoreList, err = h.Repo.GetScoreboard(0, 100)
+	if err != nil {
+		t.Fatalf("GetScoreboard: %v", err)
+	}
+	if len(scoreList) != 3 {
+		t.Fatalf("scoreboard has %d entries, want 3", len(scoreList))
+	}
+	for i, want := range []string{"bob", "carol", "alice"} {
+		if scoreList[i].Username != want {
+			t.Errorf("scoreboard[%d] = %q, want %q", i, scoreList[i].Username, want)
+		}
+	}
+	for _, score := range scoreList {
+		for _, protocol := range model.Protocols() {
+			if _, ok := score.ConquerHistoryCount[protocol.ScoreColumn]; !ok {
+				t.Errorf("%s has no %s history column", score.Username, protocol.ScoreColumn)
+			}
+		}
+	}
+}
+
+func testScoreboardTies(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob", "carol", "dave")
+	restful := model.Protocols()[0].Name
+	conquer(t, h.Repo, 1, restful, "carol")
+	conquer(t, h.Repo, 2, restful, "alice")
+	conquer(t, h.Repo, 3, restful, "bob")
+	conquer(t, h.Repo, 4, restful, "bob")
+	// carol drops to 0 after everyone else and dave reaches 1 after alice
+	conquer(t, h.Repo, 1, restful, "dave")
+
+	scoreList, err := h.Repo.GetScoreboard(0, 100)
+	if err != nil {
+		t.Fatalf("GetScoreboard: %v", err)
+	}
+	want := []string{"bob", "alice", "dave", "carol"}
+	if len(scoreList) != len(want) {
+		t.Fatalf("scoreboard = %+v, want %v", scoreList, want)
+	}
+	for i, username := range want {
+		if scoreList[i].Username != username || scoreList[i].Rank != i+1 {
+			t.Errorf("scoreboard[%d] = %s rank %d, want %s rank %d",
+				i, scoreList[i].Username, scoreList[i].Rank, username, i+1)
+		}
+	}
+
+	page, err := h.Repo.GetScoreboard(1, 2)
+	if err != nil {
+		t.Fatalf("GetScoreboard page: %v", err)
+	}
+	if len(page) != 2 || page[0].Username != "alice" || page[0].Rank != 2 || page[1].Username != "dave" {
+		t.Errorf("scoreboard page = %+v, want alice and dave from rank 2", page)
+	}
+	if page, err := h.Repo.GetScoreboard(10, 5); err != nil || len(page) != 0 {
+		t.Errorf("scoreboard beyond the end = %+v, %v, want empty", page, err)
+	}
+
+	for i, username := range want {
+		if rank := neighbourhoodRank(t, h.Repo.GetScoreNeighbourhood, username); rank != i+1 {
+			t.Errorf("rank of %s = %d, want %d", username, rank, i+1)
+		}
+	}
+	neighbours, err := h.Repo.GetScoreNeighbourhood(want[1], 1)
+	if err != nil || len(neighbours) != 3 || neighbours[0].Username != want[0] || neighbours[2].Username != want[2] {
+		t.Errorf("neighbourhood of %s = %+v, %v, want ranks 1 to 3", want[1], neighbours, err)
+	}
+	if _, err := h.Repo.GetScoreNeighbourhood("nobody", 0); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("rank of unknown user: err = %v, want ErrNotFound", err)
+	}
+}
+
+// neighbourhoodRank returns the rank of a user from its own neighbourhood
+func neighbourhoodRank(t *testing.T, neighbourhood func(username string, around int) ([]model.Score, error), username string) int {
+	t.Helper()
+	scores, err := neighbourhood(username, 0)
+	if err != nil {
+		t.Fatalf("neighbourhood of %s: %v", username, err)
+	}
+	if len(scores) != 1 || scores[0].Username != username {
+		t.Fatalf("neighbourhood of %s = %+v, want only %s", username, scores, username)
+	}
+	return scores[0].Rank
+}
+
+func testRound(t *testing.T, h Harness) {
+	if _, err := h.Repo.GetRound(); !errors.Is(err, model.ErrNotFound) {
+		t.Fatalf("GetRound without round: err = %v, want ErrNotFound", err)
+	}
+
+	start := time.UnixMilli(time.Now().UnixMilli())
+	for _, want := range []model.Round{
+		{ID: 1, StartAt: start, EndAt: start.Add(time.Hour), FreezeAt: start.Add(45 * time.Minute)},
+		{ID: 2, StartAt: start, EndAt: start.Add(time.Hour)},
+	} {
+		if err := h.Repo.SetRound(want); err != nil {
+			t.Fatalf("SetRound: %v", err)
+		}
+		got, err := h.Repo.GetRound()
+		if err != nil {
+			t.Fatalf("GetRound: %v", err)
+		}
+		if got.ID != want.ID || !got.StartAt.Equal(want.StartAt) || !got.EndAt.Equal(want.EndAt) ||
+			!got.FreezeAt.Equal(want.FreezeAt) {
+			t.Errorf("GetRound = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func testFreezeScoreboard(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob")
+	restful := model.Protocols()[0].Name
+	conquer(t, h.Repo, 1, restful, "alice")
+
+	if _, err := h.Repo.GetFrozenScoreboard(1, 0, 100); !errors.Is(err, model.ErrNotFound) {
+		t.Fatalf("GetFrozenScoreboard before freeze: err = %v, want ErrNotFound", err)
+	}
+	if err := h.Repo.FreezeScoreboard(1); err != nil {
+		t.Fatalf("FreezeScoreboard: %v", err)
+	}
+	conquer(t, h.Repo, 1, restful, "bob")
+	conquer(t, h.Repo, 2, restful, "bob")
+	// freezing again must not take a new copy
+	if err := h.Repo.FreezeScoreboard(1); err != nil {
+		t.Fatalf("FreezeScoreboard: %v", err)
+	}
+
+	frozen, err := h.Repo.GetFrozenScoreboard(1, 0, 100)
+	if err != nil {
+		t.Fatalf("GetFrozenScoreboard: %v", err)
+	}
+	if len(frozen) != 2 || frozen[0].Username != "alice" || frozen[0].ConquerFieldCount != 1 ||
+		frozen[1].ConquerFieldCount != 0 {
+		t.Errorf("frozen scoreboard = %+v, want alice 1, bob 0", frozen)
+	}
+	if history := frozen[0].ConquerHistoryCount[model.Protocols()[0].ScoreColumn]; history != 1 {
+		t.Errorf("frozen alice history = %d, want 1", history)
+	}
+	if _, err := h.Repo.GetFrozenScoreboard(2, 0, 100); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("GetFrozenScoreboard of another round: err = %v, want ErrNotFound", err)
+	}
+	frozenNeighbourhood := func(username string, around int) ([]model.Score, error) {
+		return h.Repo.GetFrozenScoreNeighbourhood(1, username, around)
+	}
+	if rank := neighbourhoodRank(t, frozenNeighbourhood, "bob"); rank != 2 {
+		t.Errorf("frozen rank of bob = %d, want 2", rank)
+	}
+	if rank := neighbourhoodRank(t, h.Repo.GetScoreNeighbourhood, "bob"); rank != 1 {
+		t.Errorf("live rank of bob = %d, want 1", rank)
+	}
+
+	if live := scoreMap(t, h.Repo); live["bob"].ConquerFieldCount != 2 {
+		t.Errorf("live bob count = %d, want 2", live["bob"].ConquerFieldCount)
+	}
+}
+
+func testArchiveRound(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob")
+	restful := model.Protocols()[0].Name
+	conquer(t, h.Repo, 1, restful, "alice")
+	conquer(t, h.Repo, 2, restful, "alice")
+	conquer(t, h.Repo, 3, restful, "bob")
+	if err := h.Repo.FreezeScoreboard(1); err != nil {
+		t.Fatalf("FreezeScoreboard: %v", err)
+	}
+
+	if _, err := h.Repo.GetArchivedScoreboard(1, 0, 100); !errors.Is(err, model.ErrNotFound) {
+		t.Fatalf("GetArchivedScoreboard before archive: err = %v, want ErrNotFound", err)
+	}
+	if err := h.Repo.ArchiveRound(1); err != nil {
+		t.Fatalf("ArchiveRound: %v", err)
+	}
+
+	archived, err := h.Repo.GetArchivedScoreboard(1, 0, 100)
+	if err != nil {
+		t.Fatalf("GetArchivedScoreboard: %v", err)
+	}
+	if len(archived) != 2 || archived[0].Username != "alice" || archived[0].ConquerFieldCount != 2 {
+		t.Errorf("archived scoreboard = %+v, want alice 2 first", archived)
+	}
+
+	// the next round starts from an empty map with every user at zero
+	live := scoreMap(t, h.Repo)
+	if len(live) != 2 || live["alice"].ConquerFieldCount != 0 || live["bob"].ConquerFieldCount != 0 {
+		t.Errorf("scoreboard after archive = %+v, want alice and bob at 0", live)
+	}
+	if history := live["alice"].ConquerHistoryCount[model.Protocols()[0].ScoreColumn]; history != 0 {
+		t.Errorf("alice history after archive = %d, want 0", history)
+	}
+	fieldMap, err := h.Repo.GetMap(1, 3)
+	if err != nil {
+		t.Fatalf("GetMap: %v", err)
+	}
+	for _, field := range fieldMap.Fields {
+		for _, owner := range field.Conquerer {
+			if owner.Owner != "" {
+				t.Errorf("field %d %s owned by %q after archive", field.FieldID, owner.ConquerType, owner.Owner)
+			}
+		}
+	}
+	if fields, err := h.Repo.GetUserConquerField("alice", restful); err != nil || len(fields) != 0 {
+		t.Errorf("alice fields after archive = %v, %v, want none", fields, err)
+	}
+	if _, err := h.Repo.GetFrozenScoreboard(1, 0, 100); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("GetFrozenScoreboard after archive: err = %v, want ErrNotFound", err)
+	}
+
+	// archiving twice must not wipe the archive with the reset state
+	conquer(t, h.Repo, 1, restful, "bob")
+	if err := h.Repo.ArchiveRound(1); err != nil {
+		t.Fatalf("ArchiveRound again: %v", err)
+	}
+	if previous := conquer(t, h.Repo, 1, restful, "alice"); previous != "bob" {
+		t.Errorf("second ArchiveRound reset the map, previous owner = %q", previous)
+	}
+	archived, err = h.Repo.GetArchivedScoreboard(1, 0, 100)
+	if err != nil {
+		t.Fatalf("GetArchivedScoreboard: %v", err)
+	}
+	if archived[0].Username != "alice" || archived[0].ConquerFieldCount != 2 {
+		t.Errorf("archive changed by second ArchiveRound: %+v", archived)
+	}
+}
+
+func testRoleAndBan(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice")
+
+	user, err := h.Repo.GetUser("alice")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if user.IsAdmin() || user.Banned {
+		t.Errorf("new user = %+v, want player not banned", user)
+	}
+
+	if err := h.Repo.SetRole("alice", model.RoleAdmin); err != nil {
+		t.Fatalf("SetRole: %v", err)
+	}
+	if err := h.Repo.SetBanned("alice", true); err != nil {
+		t.Fatalf("SetBanned: %v", err)
+	}
+	user, err = h.Repo.GetUser("alice")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if !user.IsAdmin() || !user.Banned {
+		t.Errorf("user = %+v, want banned admin", user)
+	}
+	if user.Password != "password-alice" {
+		t.Errorf("SetRole changed the password to %q", user.Password)
+	}
+
+	if err := h.Repo.SetBanned("alice", false); err != nil {
+		t.Fatalf("SetBanned: %v", err)
+	}
+	if user, _ := h.Repo.GetUser("alice"); user.Banned {
+		t.Error("user still banned after unban")
+	}
+
+	if err := h.Repo.SetRole("nobody", model.RoleAdmin); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("SetRole unknown user: err = %v, want ErrNotFound", err)
+	}
+	if err := h.Repo.SetBanned("nobody", true); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("SetBanned unknown user: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testUserStats(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob")
+	restful := model.Protocols()[0]
+	conquer(t, h.Repo, 1, restful.Name, "bob")
+	conquer(t, h.Repo, 2, restful.Name, "bob")
+	conquer(t, h.Repo, 1, restful.Name, "alice")
+	if err := h.Repo.SetBanned("bob", true); err != nil {
+		t.Fatalf("SetBanned: %v", err)
+	}
+
+	stats, err := h.Repo.GetUserStats()
+	if err != nil {
+		t.Fatalf("GetUserStats: %v", err)
+	}
+	if len(stats) != 2 || stats[0].Username != "alice" || stats[1].Username != "bob" {
+		t.Fatalf("GetUserStats = %+v, want alice and bob in id order", stats)
+	}
+	alice, bob := stats[0], stats[1]
+	if alice.Role != model.RolePlayer || alice.Banned || alice.ConquerFieldCount != 1 {
+		t.Errorf("alice = %+v", alice)
+	}
+	if !bob.Banned || bob.ConquerFieldCount != 1 || bob.ConquerHistoryCount[restful.ScoreColumn] != 2 {
+		t.Errorf("bob = %+v", bob)
+	}
+}
+
+func testSetFieldOwner(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob")
+	restful := model.Protocols()[0].Name
+	conquer(t, h.Repo, 5, restful, "alice")
+
+	previous, err := h.Repo.SetFieldOwner(5, restful, "bob")
+	if err != nil {
+		t.Fatalf("SetFieldOwner: %v", err)
+	}
+	if previous != "alice" {
+		t.Errorf("previous owner = %q, want alice", previous)
+	}
+	scores := scoreMap(t, h.Repo)
+	if scores["alice"].ConquerFieldCount != 0 || scores["bob"].ConquerFieldCount != 1 {
+		t.Errorf("counts after SetFieldOwner = alice %d, bob %d, want 0 and 1",
+			scores["alice"].ConquerFieldCount, scores["bob"].ConquerFieldCount)
+	}
+	// a forced owner is not a conquer
+	if history := scores["bob"].ConquerHistoryCount[model.Protocols()[0].ScoreColumn]; history != 0 {
+		t.Errorf("bob history = %d, want 0", history)
+	}
+	if fields, _ := h.Repo.GetUserConquerField("bob", restful); !reflect.DeepEqual(fields, []int{5}) {
+		t.Errorf("bob fields = %v, want [5]", fields)
+	}
+
+	if previous, err := h.Repo.SetFieldOwner(5, restful, ""); err != nil || previous != "bob" {
+		t.Fatalf("SetFieldOwner free = %q, %v, want bob", previous, err)
+	}
+	if previous := conquer(t, h.Repo, 5, restful, "alice"); previous != "" {
+		t.Errorf("freed field had owner %q", previous)
+	}
+	if fields, _ := h.Repo.GetUserConquerField("bob", restful); len(fields) != 0 {
+		t.Errorf("bob fields after free = %v, want none", fields)
+	}
+}
+
+func testWipeUserFields(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob")
+	protocols := model.Protocols()
+	for _, fieldID := range []int{1, 8, 9, 1000} {
+		conquer(t, h.Repo, fieldID, protocols[0].Name, "alice")
+	}
+	conquer(t, h.Repo, 3, protocols[1].Name, "alice")
+	conquer(t, h.Repo, 2, protocols[0].Name, "bob")
+
+	version := mapVersion(t, h.Repo)
+	freed, err := h.Repo.WipeUserFields("alice")
+	if err != nil {
+		t.Fatalf("WipeUserFields: %v", err)
+	}
+	if len(freed) != 5 {
+		t.Errorf("freed = %+v, want 5 fields", freed)
+	}
+	for _, change := range freed {
+		if change.Owner != "" || change.Version != version+1 || (change.FieldID == 3) != (change.ConquerType == protocols[1].Name) {
+			t.Errorf("freed field = %+v, want a free change at version %d", change, version+1)
+		}
+	}
+
+	scores := scoreMap(t, h.Repo)
+	if scores["alice"].ConquerFieldCount != 0 || scores["bob"].ConquerFieldCount != 1 {
+		t.Errorf("counts after wipe = alice %d, bob %d, want 0 and 1",
+			scores["alice"].ConquerFieldCount, scores["bob"].ConquerFieldCount)
+	}
+	for _, protocol := range protocols {
+		if fields, _ := h.Repo.GetUserConquerField("alice", protocol.Name); len(fields) != 0 {
+			t.Errorf("alice %s fields after wipe = %v", protocol.Name, fields)
+		}
+	}
+	if previous := conquer(t, h.Repo, 8, protocols[0].Name, "bob"); previous != "" {
+		t.Errorf("wiped field still owned by %q", previous)
+	}
+	if previous := conquer(t, h.Repo, 2, protocols[0].Name, "alice"); previous != "bob" {
+		t.Errorf("wipe touched bob's field, previous owner = %q", previous)
+	}
+}
+
+func testAuditLog(t *testing.T, h Harness) {
+	entries, err := h.Repo.GetAuditLog(10)
+	if err != nil {
+		t.Fatalf("GetAuditLog on empty repo: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("empty audit log = %+v", entries)
+	}
+
+	now := time.UnixMilli(time.Now().UnixMilli()).UTC()
+	for i, action := range []string{"ban", "unban", "wipe"} {
+		if err := h.Repo.AddAuditEntry(model.AuditEntry{
+			Time:   now.Add(time.Duration(i) * time.Second),
+			Admin:  "root",
+			Action: action,
+			Target: "alice",
+		}); err != nil {
+			t.Fatalf("AddAuditEntry: %v", err)
+		}
+	}
+
+	entries, err = h.Repo.GetAuditLog(2)
+	if err != nil {
+		t.Fatalf("GetAuditLog: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Action != "wipe" || entries[1].Action != "unban" {
+		t.Fatalf("GetAuditLog(2) = %+v, want wipe and unban", entries)
+	}
+	if !entries[0].Time.Equal(now.Add(2*time.Second)) || entries[0].Admin != "root" || entries[0].Target != "alice" {
+		t.Errorf("entry = %+v", entries[0])
+	}
+}
+
+func mapVersion(t *testing.T, repo model.Repo) uint64 {
+	t.Helper()
+	version, err := repo.GetMapVersion()
+	if err != nil {
+		t.Fatalf("GetMapVersion: %v", err)
+	}
+	return version
+}
+
+func testMapVersion(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob")
+	conquerType := model.Protocols()[0].Name
+
+	initial := mapVersion(t, h.Repo)
+	conquer(t, h.Repo, 1, conquerType, "alice")
+	afterConquer := mapVersion(t, h.Repo)
+	if afterConquer <= initial {
+		t.Fatalf("version after conquer = %d, want more than %d", afterConquer, initial)
+	}
+
+	conquer(t, h.Repo, 1, conquerType, "alice")
+	if version := mapVersion(t, h.Repo); version != afterConquer {
+		t.Errorf("version after conquering own field = %d, want %d", version, afterConquer)
+	}
+
+	if _, err := h.Repo.SetFieldOwner(1, conquerType, "bob"); err != nil {
+		t.Fatalf("SetFieldOwner: %v", err)
+	}
+	afterSet := mapVersion(t, h.Repo)
+	if afterSet <= afterConquer {
+		t.Errorf("version after SetFieldOwner = %d, want more than %d", afterSet, afterConquer)
+	}
+
+	if _, err := h.Repo.WipeUserFields("alice"); err != nil {
+		t.Fatalf("WipeUserFields: %v", err)
+	}
+	if version := mapVersion(t, h.Repo); version != afterSet {
+		t.Errorf("version after wiping a user without fields = %d, want %d", version, afterSet)
+	}
+}
+
+func testMapSnapshot(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob")
+	protocols := model.Protocols()
+	conquer(t, h.Repo, 1, protocols[0].Name, "alice")
+	conquer(t, h.Repo, 10, protocols[0].Name, "bob")
+	conquer(t, h.Repo, 3, protocols[1].Name, "bob")
+	// outside of the snapshot
+	conquer(t, h.Repo, 11, protocols[1].Name, "alice")
+
+	users, err := h.Repo.GetUserList()
+	if err != nil {
+		t.Fatalf("GetUserList: %v", err)
+	}
+	ids := make(map[string]uint32, len(users))
+	for _, user := range users {
+		ids[user.Username] = uint32(user.ID)
+	}
+
+	snapshot, err := h.Repo.GetMapSnapshot(10)
+	if err != nil {
+		t.Fatalf("GetMapSnapshot: %v", err)
+	}
+	if snapshot.Version != mapVersion(t, h.Repo) {
+		t.Errorf("snapshot version = %d, want %d", snapshot.Version, mapVersion(t, h.Repo))
+	}
+	want := map[string][]uint32{
+		protocols[0].Name: {ids["alice"], 0, 0, 0, 0, 0, 0, 0, 0, ids["bob"]},
+		protocols[1].Name: {0, 0, ids["bob"], 0, 0, 0, 0, 0, 0, 0},
+	}
+	if !reflect.DeepEqual(snapshot.Owners, want) {
+		t.Errorf("snapshot owners = %v, want %v", snapshot.Owners, want)
+	}
+
+	// the binary encoding round trips
+	data, err := snapshot.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	var decoded model.MapSnapshot
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, snapshot) {
+		t.Errorf("decoded snapshot = %+v, want %+v", decoded, snapshot)
+	}
+	if err := decoded.UnmarshalBinary(data[:len(data)-1]); !errors.Is(err, model.ErrInvalidSnapshot) {
+		t.Errorf("UnmarshalBinary of truncated data = %v, want ErrInvalidSnapshot", err)
+	}
+}
+
+func mapChanges(t *testing.T, repo model.Repo, since uint64) model.MapChanges {
+	t.Helper()
+	changes, err := repo.GetMapChanges(since)
+	if err != nil {
+		t.Fatalf("GetMapChanges(%d): %v", since, err)
+	}
+	return changes
+}
+
+func testMapChanges(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob")
+	protocols := model.Protocols()
+
+	start := mapVersion(t, h.Repo)
+	conquer(t, h.Repo, 1, protocols[0].Name, "alice")
+	conquer(t, h.Repo, 1, protocols[0].Name, "bob")
+	conquer(t, h.Repo, 2, protocols[1].Name, "alice")
+	// no change
+	conquer(t, h.Repo, 2, protocols[1].Name, "alice")
+
+	changes := mapChanges(t, h.Repo, start)
+	if changes.Version != mapVersion(t, h.Repo) {
+		t.Errorf("changes version = %d, want %d", changes.Version, mapVersion(t, h.Repo))
+	}
+	want := []model.MapChange{
+		{Version: start + 1, FieldID: 1, ConquerType: protocols[0].Name, Owner: "alice"},
+		{Version: start + 2, FieldID: 1, ConquerType: protocols[0].Name, Owner: "bob"},
+		{Version: start + 3, FieldID: 2, ConquerType: protocols[1].Name, Owner: "alice"},
+	}
+	if !reflect.DeepEqual(changes.Changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes.Changes, want)
+	}
+	if latest := mapChanges(t, h.Repo, start+2); !reflect.DeepEqual(latest.Changes, want[2:]) {
+		t.Errorf("changes since %d = %+v, want %+v", start+2, latest.Changes, want[2:])
+	}
+	if latest := mapChanges(t, h.Repo, changes.Version); len(latest.Changes) != 0 {
+		t.Errorf("changes since current version = %+v, want none", latest.Changes)
+	}
+
+	_, err := h.Repo.GetMapChanges(changes.Version + 1)
+	var resync *model.ResyncRequiredError
+	if !errors.As(err, &resync) || resync.Version != changes.Version {
+		t.Errorf("GetMapChanges of a future version = %v, want ResyncRequiredError at %d", err, changes.Version)
+	}
+
+	// freed fields are changes to an empty owner
+	if _, err := h.Repo.WipeUserFields("alice"); err != nil {
+		t.Fatalf("WipeUserFields: %v", err)
+	}
+	if _, err := h.Repo.SetFieldOwner(1, protocols[0].Name, ""); err != nil {
+		t.Fatalf("SetFieldOwner: %v", err)
+	}
+	want = []model.MapChange{
+		{Version: changes.Version + 1, FieldID: 2, ConquerType: protocols[1].Name, Owner: ""},
+		{Version: changes.Version + 2, FieldID: 1, ConquerType: protocols[0].Name, Owner: ""},
+	}
+	if freed := mapChanges(t, h.Repo, changes.Version); !reflect.DeepEqual(freed.Changes, want) {
+		t.Errorf("changes after freeing = %+v, want %+v", freed.Changes, want)
+	}
+
+	// a round reset can't be replayed
+	beforeArchive := mapVersion(t, h.Repo)
+	if err := h.Repo.ArchiveRound(1); err != nil {
+		t.Fatalf("ArchiveRound: %v", err)
+	}
+	if _, err := h.Repo.GetMapChanges(beforeArchive); !errors.Is(err, model.ErrResyncRequired) {
+		t.Errorf("GetMapChanges across a round reset = %v, want ErrResyncRequired", err)
+	}
+	afterArchive := mapVersion(t, h.Repo)
+	if after := mapChanges(t, h.Repo, afterArchive); len(after.Changes) != 0 {
+		t.Errorf("changes after reset = %+v, want none", after.Changes)
+	}
+	conquer(t, h.Repo, 5, protocols[0].Name, "bob")
+	if after := mapChanges(t, h.Repo, afterArchive); len(after.Changes) != 1 {
+		t.Errorf("changes after reset and conquer = %+v, want one", after.Changes)
+	}
+}
+
+func fieldHistory(t *testing.T, repo model.Repo, fieldID int) []model.FieldChange {
+	t.Helper()
+	history, err := repo.GetFieldHistory(fieldID)
+	if err != nil {
+		t.Fatalf("GetFieldHistory(%d): %v", fieldID, err)
+	}
+	return history
+}
+
+func testFieldHistory(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob", "carol")
+	protocols := model.Protocols()
+
+	if history := fieldHistory(t, h.Repo, 1); len(history) != 0 {
+		t.Errorf("history of a free field = %+v, want none", history)
+	}
+
+	conquer(t, h.Repo, 1, protocols[0].Name, "alice")
+	conquer(t, h.Repo, 1, protocols[0].Name, "alice")
+	conquer(t, h.Repo, 1, protocols[0].Name, "bob")
+	conquer(t, h.Repo, 1, protocols[1].Name, "carol")
+	if _, err := h.Repo.SetFieldOwner(1, protocols[0].Name, ""); err != nil {
+		t.Fatalf("SetFieldOwner: %v", err)
+	}
+	if _, err := h.Repo.WipeUserFields("carol"); err != nil {
+		t.Fatalf("WipeUserFields: %v", err)
+	}
+	conquer(t, h.Repo, 2, protocols[0].Name, "bob")
+
+	want := []model.FieldChange{
+		{ConquerType: protocols[1].Name, Owner: ""},
+		{ConquerType: protocols[0].Name, Owner: ""},
+		{ConquerType: protocols[1].Name, Owner: "carol"},
+		{ConquerType: protocols[0].Name, Owner: "bob"},
+		{ConquerType: protocols[0].Name, Owner: "alice"},
+	}
+	history := fieldHistory(t, h.Repo, 1)
+	if len(history) != len(want) {
+		t.Fatalf("history = %+v, want %d changes", history, len(want))
+	}
+	for i := range want {
+		if history[i].ConquerType != want[i].ConquerType || history[i].Owner != want[i].Owner {
+			t.Errorf("history[%d] = %s %q, want %s %q",
+				i, history[i].ConquerType, history[i].Owner, want[i].ConquerType, want[i].Owner)
+		}
+		if history[i].Time.IsZero() || (i > 0 && history[i].Time.After(history[i-1].Time)) {
+			t.Errorf("history[%d] time %v is not newest first", i, history[i].Time)
+		}
+	}
+
+	times, err := h.Repo.GetArchiveTimes()
+	if err != nil {
+		t.Fatalf("GetArchiveTimes: %v", err)
+	}
+	if len(times) != 0 {
+		t.Errorf("archive times before any archive = %v", times)
+	}
+	if err := h.Repo.ArchiveRound(1); err != nil {
+		t.Fatalf("ArchiveRound: %v", err)
+	}
+	if times, err = h.Repo.GetArchiveTimes(); err != nil || len(times) != 1 {
+		t.Errorf("archive times = %v, %v, want one", times, err)
+	}
+	// history outlives the round
+	if history := fieldHistory(t, h.Repo, 2); len(history) != 1 || history[0].Owner != "bob" {
+		t.Errorf("history after archive = %+v, want bob", history)
+	}
+}
+
+func testFieldHistoryLimit(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob", "carol")
+	protocols := model.Protocols()
+	conquerType := protocols[0].Name
+	// the limit is per conquer type, a busy type doesn't push out the others
+	conquer(t, h.Repo, 1, protocols[1].Name, "carol")
+	owners := []string{"alice", "bob"}
+	for i := 0; i <= model.FieldHistoryLimit; i++ {
+		conquer(t, h.Repo, 1, conquerType, owners[i%2])
+	}
+
+	history := fieldHistory(t, h.Repo, 1)
+	if len(history) != model.FieldHistoryLimit+1 {
+		t.Fatalf("history has %d changes, want %d", len(history), model.FieldHistoryLimit+1)
+	}
+	oldest := history[len(history)-1]
+	if oldest.ConquerType != protocols[1].Name || oldest.Owner != "carol" {
+		t.Errorf("oldest change = %+v, want carol over %s", oldest, protocols[1].Name)
+	}
+	// the limit is even, so the oldest kept change is bob's
+	if history[0].Owner != owners[model.FieldHistoryLimit%2] || history[len(history)-2].Owner != "bob" {
+		t.Errorf("history runs from %q to %q", history[0].Owner, history[len(history)-2].Owner)
+	}
+}
+
+func scoreMap(t *testing.T, repo model.Repo) map[string]model.Score {
+	t.Helper()
+	scoreList, err := repo.GetScoreboard(0, 100)
+	if err != nil {
+		t.Fatalf("GetScoreboard: %v", err)
+	}
+	scores := make(map[string]model.Score, len(scoreList))
+	for _, score := range scoreList {
+		scores[score.Username] = score
+	}
+	return scores
+}
+
+func testTeams(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob", "carol")
+
+	if teams, err := h.Repo.GetTeams(); err != nil || len(teams) != 0 {
+		t.Fatalf("GetTeams without teams = %+v, %v, want none", teams, err)
+	}
+	if _, err := h.Repo.GetTeam(1); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("GetTeam of unknown team: got %v, want ErrNotFound", err)
+	}
+
+	red, err := h.Repo.CreateTeam("red", "alice")
+	if err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+	if red.ID == 0 || red.Name != "red" || len(red.Members) != 1 || red.Members[0] != "alice" {
+		t.Errorf("CreateTeam = %+v, want red with alice", red)
+	}
+	if _, err := h.Repo.CreateTeam("red", "bob"); !errors.Is(err, model.ErrTeamExist) {
+		t.Errorf("CreateTeam with taken name: got %v, want ErrTeamExist", err)
+	}
+	if _, err := h.Repo.CreateTeam("blue", "alice"); !errors.Is(err, model.ErrAlreadyInTeam) {
+		t.Errorf("CreateTeam by team member: got %v, want ErrAlreadyInTeam", err)
+	}
+	user, err := h.Repo.GetUser("alice")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if user.TeamID != red.ID {
+		t.Errorf("TeamID of alice = %d, want %d", user.TeamID, red.ID)
+	}
+
+	if err := h.Repo.JoinTeam(red.ID, "bob"); !errors.Is(err, model.ErrNotInvited) {
+		t.Errorf("JoinTeam without invite: got %v, want ErrNotInvited", err)
+	}
+	if err := h.Repo.InviteToTeam(red.ID, "nobody"); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("InviteToTeam of unknown user: got %v, want ErrNotFound", err)
+	}
+	if err := h.Repo.InviteToTeam(red.ID+1, "bob"); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("InviteToTeam to unknown team: got %v, want ErrNotFound", err)
+	}
+	for _, username := range []string{"bob", "carol", "bob"} {
+		if err := h.Repo.InviteToTeam(red.ID, username); err != nil {
+			t.Fatalf("InviteToTeam(%q): %v", username, err)
+		}
+	}
+	if err := h.Repo.JoinTeam(red.ID, "bob"); err != nil {
+		t.Fatalf("JoinTeam: %v", err)
+	}
+
+	blue, err := h.Repo.CreateTeam("blue", "carol")
+	if err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+	if err := h.Repo.JoinTeam(red.ID, "carol"); !errors.Is(err, model.ErrAlreadyInTeam) {
+		t.Errorf("JoinTeam by member of another team: got %v, want ErrAlreadyInTeam", err)
+	}
+
+	teams, err := h.Repo.GetTeams()
+	if err != nil {
+		t.Fatalf("GetTeams: %v", err)
+	}
+	if len(teams) != 2 || teams[0].ID != red.ID || teams[1].ID != blue.ID {
+		t.Fatalf("GetTeams = %+v, want red and blue in id order", teams)
+	}
+	if got := teams[0]; len(got.Members) != 2 || got.Members[0] != "alice" || got.Members[1] != "bob" {
+		t.Errorf("members of red = %v, want [alice bob]", got.Members)
+	}
+	if got := teams[0]; len(got.Invites) != 1 || got.Invites[0] != "carol" {
+		t.Errorf("invites of red = %v, want [carol]", got.Invites)
+	}
+	if got, err := h.Repo.GetTeam(blue.ID); err != nil || got.Name != "blue" || len(got.Members) != 1 {
+		t.Errorf("GetTeam(blue) = %+v, %v", got, err)
+	}
+}
+
+func testTeamFull(t *testing.T, h Harness) {
+	usernames := make([]string, 0, model.MaxTeamSize+1)
+	for i := 0; i <= model.MaxTeamSize; i++ {
+		usernames = append(usernames, fmt.Sprintf("user%d", i))
+	}
+	createUsers(t, h.Repo, usernames...)
+
+	team, err := h.Repo.CreateTeam("full", usernames[0])
+	if err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+	for _, username := range usernames[1:] {
+		if err := h.Repo.InviteToTeam(team.ID, username); err != nil {
+			t.Fatalf("InviteToTeam(%q): %v", username, err)
+		}
+	}
+	for _, username := range usernames[1:model.MaxTeamSize] {
+		if err := h.Repo.JoinTeam(team.ID, username); err != nil {
+			t.Fatalf("JoinTeam(%q): %v", username, err)
+		}
+	}
+	if err := h.Repo.JoinTeam(team.ID, usernames[model.MaxTeamSize]); !errors.Is(err, model.ErrTeamFull) {
+		t.Errorf("JoinTeam of a full team: got %v, want ErrTeamFull", err)
+	}
+	if user, err := h.Repo.GetUser(usernames[model.MaxTeamSize]); err != nil || user.TeamID != 0 {
+		t.Errorf("user turned away = %+v, %v, want no team", user, err)
+	}
+}
+
+func testTeammateConquer(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob", "carol")
+	protocol := model.Protocols()[0].Name
+
+	team, err := h.Repo.CreateTeam("red", "alice")
+	if err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+	if err := h.Repo.InviteToTeam(team.ID, "bob"); err != nil {
+		t.Fatalf("InviteToTeam: %v", err)
+	}
+	if err := h.Repo.JoinTeam(team.ID, "bob"); err != nil {
+		t.Fatalf("JoinTeam: %v", err)
+	}
+
+	conquer(t, h.Repo, 1, protocol, "alice")
+	version := mapVersion(t, h.Repo)
+	if _, err := h.Repo.Conquer(1, protocol, "bob", model.FieldLock{}); !errors.Is(err, model.ErrTeammateField) {
+		t.Fatalf("Conquer of a teammate's field: got %v, want ErrTeammateField", err)
+	}
+	if got := mapVersion(t, h.Repo); got != version {
+		t.Errorf("map version after blocked conquer = %d, want %d", got, version)
+	}
+	scores := scoreMap(t, h.Repo)
+	if scores["alice"].ConquerFieldCount != 1 || scores["bob"].ConquerFieldCount != 0 ||
+		scores["bob"].ConquerHistoryCount[model.Protocols()[0].ScoreColumn] != 0 {
+		t.Errorf("scores after blocked conquer = %+v", scores)
+	}
+
+	// other teams and players without a team still steal
+	if previous := conquer(t, h.Repo, 1, protocol, "carol"); previous != "alice" {
+		t.Errorf("previous owner = %q, want alice", previous)
+	}
+	if previous := conquer(t, h.Repo, 1, protocol, "bob"); previous != "carol" {
+		t.Errorf("previous owner = %q, want carol", previous)
+	}
+}
+
+func testTeamScores(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob", "carol")
+	protocols := model.Protocols()
+
+	red, err := h.Repo.CreateTeam("red", "alice")
+	if err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+	if err := h.Repo.InviteToTeam(red.ID, "bob"); err != nil {
+		t.Fatalf("InviteToTeam: %v", err)
+	}
+	if err := h.Repo.JoinTeam(red.ID, "bob"); err != nil {
+		t.Fatalf("JoinTeam: %v", err)
+	}
+	blue, err := h.Repo.CreateTeam("blue", "carol")
+	if err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+
+	conquer(t, h.Repo, 1, protocols[0].Name, "alice")
+	conquer(t, h.Repo, 2, protocols[0].Name, "alice")
+	conquer(t, h.Repo, 1, protocols[0].Name, "carol")
+	conquer(t, h.Repo, 3, protocols[1].Name, "bob")
+
+	if err := h.Repo.FreezeScoreboard(1); err != nil {
+		t.Fatalf("FreezeScoreboard: %v", err)
+	}
+	conquer(t, h.Repo, 4, protocols[1].Name, "bob")
+
+	check := func(name string, scores []model.TeamScore, redCount int) {
+		t.Helper()
+		if len(scores) != 2 || scores[0].TeamID != red.ID || scores[1].TeamID != blue.ID {
+			t.Fatalf("%s = %+v, want red and blue in id order", name, scores)
+		}
+		if scores[0].ConquerFieldCount != redCount || len(scores[0].Members) != 2 {
+			t.Errorf("%s red = %+v, want %d held fields by 2 members", name, scores[0], redCount)
+		}
+		if got := scores[0].ConquerHistoryCount[protocols[0].ScoreColumn]; got != 2 {
+			t.Errorf("%s red %s conquers = %d, want 2", name, protocols[0].Name, got)
+		}
+		if got := scores[0].ConquerHistoryCount[protocols[1].ScoreColumn]; got != redCount-1 {
+			t.Errorf("%s red %s conquers = %d, want %d", name, protocols[1].Name, got, redCount-1)
+		}
+		if scores[1].ConquerFieldCount != 1 || scores[1].ConquerHistoryCount[protocols[0].ScoreColumn] != 1 {
+			t.Errorf("%s blue = %+v, want 1 held field", name, scores[1])
+		}
+	}
+
+	scores, err := h.Repo.GetTeamScores()
+	if err != nil {
+		t.Fatalf("GetTeamScores: %v", err)
+	}
+	check("GetTeamScores", scores, 3)
+
+	frozen, err := h.Repo.GetFrozenTeamScores(1)
+	if err != nil {
+		t.Fatalf("GetFrozenTeamScores: %v", err)
+	}
+	check("GetFrozenTeamScores", frozen, 2)
+	if _, err := h.Repo.GetFrozenTeamScores(2); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("GetFrozenTeamScores of another round: got %v, want ErrNotFound", err)
+	}
+}
+
+func testFieldLock(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob")
+	protocols := model.Protocols()
+	lock := model.FieldLock{Duration: time.Second, FirstCapture: 5 * time.Second}
+
+	locked := func(fieldID int, conquerType, username string) *model.FieldLockedError {
+		t.Helper()
+		_, err := h.Repo.Conquer(fieldID, conquerType, username, lock)
+		var lockedErr *model.FieldLockedError
+		if !errors.As(err, &lockedErr) || !errors.Is(err, model.ErrFieldLocked) {
+			t.Fatalf("Conquer(%d, %q, %q) of a locked field: got %v, want FieldLockedError", fieldID, conquerType, username, err)
+		}
+		return lockedErr
+	}
+	conquerLocked := func(fieldID int, conquerType, username string) string {
+		t.Helper()
+		previous, err := h.Repo.Conquer(fieldID, conquerType, username, lock)
+		if err != nil {
+			t.Fatalf("Conquer(%d, %q, %q): %v", fieldID, conquerType, username, err)
+		}
+		return previous
+	}
+
+	// a free field gets the longer first capture shield
+	start := time.Now()
+	conquerLocked(1, protocols[0].Name, "alice")
+	version := mapVersion(t, h.Repo)
+	err := locked(1, protocols[0].Name, "bob")
+	if err.UnlockAt.Before(start.Add(4*time.Second)) || err.UnlockAt.After(time.Now().Add(6*time.Second)) {
+		t.Errorf("UnlockAt = %v, want about 5s after %v", err.UnlockAt, start)
+	}
+	if got := mapVersion(t, h.Repo); got != version {
+		t.Errorf("map version after locked conquer = %d, want %d", got, version)
+	}
+	if scores := scoreMap(t, h.Repo); scores["bob"].ConquerFieldCount != 0 {
+		t.Errorf("bob's score after locked conquer = %+v", scores["bob"])
+	}
+	// locks are per field and conquer type
+	conquerLocked(1, protocols[1].Name, "bob")
+	conquerLocked(2, protocols[0].Name, "bob")
+
+	h.Advance(5*time.Second + 100*time.Millisecond)
+	if previous := conquerLocked(1, protocols[0].Name, "bob"); previous != "alice" {
+		t.Errorf("previous owner = %q, want alice", previous)
+	}
+	// a steal locks for the shorter duration
+	locked(1, protocols[0].Name, "alice")
+	h.Advance(time.Second + 100*time.Millisecond)
+	if previous := conquerLocked(1, protocols[0].Name, "alice"); previous != "bob" {
+		t.Errorf("previous owner = %q, want bob", previous)
+	}
+
+	// without a lock fields can be retaken at once
+	conquer(t, h.Repo, 3, protocols[0].Name, "alice")
+	conquer(t, h.Repo, 3, protocols[0].Name, "bob")
+
+	// locks end with their round
+	locked(1, protocols[0].Name, "bob")
+	if err := h.Repo.ArchiveRound(1); err != nil {
+		t.Fatalf("ArchiveRound: %v", err)
+	}
+	if previous := conquerLocked(1, protocols[0].Name, "bob"); previous != "" {
+		t.Errorf("previous owner after archive = %q, want a free field", previous)
+	}
+}
+
+func testHoldTime(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob")
+	restful := model.Protocols()[0].Name
+	now := time.Now()
+	round := model.Round{
+		ID:      1,
+		StartAt: now.Add(-time.Minute),
+		EndAt:   now.Add(time.Hour),
+		Scoring: model.ScoringHoldTime,
+	}
+	if err := h.Repo.SetRound(round); err != nil {
+		t.Fatalf("SetRound: %v", err)
+	}
+
+	// alice holds one field for a second before bob takes two
+	conquer(t, h.Repo, 1, restful, "alice")
+	h.Advance(1100 * time.Millisecond)
+	conquer(t, h.Repo, 2, restful, "bob")
+	conquer(t, h.Repo, 3, restful, "bob")
+
+	scores, err := h.Repo.GetScoreboard(0, 100)
+	if err != nil {
+		t.Fatalf("GetScoreboard: %v", err)
+	}
+	if len(scores) != 2 || scores[0].Username != "alice" || scores[0].HoldPoints != 1 || scores[1].HoldPoints != 0 {
+		t.Errorf("hold time scoreboard = %+v, want alice with 1 point first", scores)
+	}
+	if rank := neighbourhoodRank(t, h.Repo.GetScoreNeighbourhood, "bob"); rank != 2 {
+		t.Errorf("rank of bob = %d, want 2", rank)
+	}
+	// teams sum the held time of their members
+	if _, err := h.Repo.CreateTeam("red", "alice"); err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+	teams, err := h.Repo.GetTeamScores()
+	if err != nil {
+		t.Fatalf("GetTeamScores: %v", err)
+	}
+	if len(teams) != 1 || teams[0].HoldPoints != 1 {
+		t.Errorf("team scores = %+v, want red with 1 point", teams)
+	}
+
+	// held time is shown but not ranked by with conquer scoring
+	round.Scoring = model.ScoringConquers
+	if err := h.Repo.SetRound(round); err != nil {
+		t.Fatalf("SetRound: %v", err)
+	}
+	scores, err = h.Repo.GetScoreboard(0, 100)
+	if err != nil {
+		t.Fatalf("GetScoreboard: %v", err)
+	}
+	if len(scores) != 2 || scores[0].Username != "bob" || scores[1].HoldPoints != 1 {
+		t.Errorf("conquer scoreboard = %+v, want bob first and alice with 1 point", scores)
+	}
+
+	round.Scoring = model.ScoringHoldTime
+	if err := h.Repo.SetRound(round); err != nil {
+		t.Fatalf("SetRound: %v", err)
+	}
+	if err := h.Repo.ArchiveRound(1); err != nil {
+		t.Fatalf("ArchiveRound: %v", err)
+	}
+	archived, err := h.Repo.GetArchivedScoreboard(1, 0, 100)
+	if err != nil {
+		t.Fatalf("GetArchivedScoreboard: %v", err)
+	}
+	if len(archived) != 2 || archived[0].Username != "alice" || archived[0].HoldPoints != 1 {
+		t.Errorf("archived scoreboard = %+v, want alice with 1 point first", archived)
+	}
+	// the next round starts without held time
+	if live := scoreMap(t, h.Repo); live["alice"].HoldPoints != 0 {
+		t.Errorf("alice after archive = %+v, want 0 points", live["alice"])
+	}
+}
+
+func testConquerFields(t *testing.T, h Harness) {
+	createUsers(t, h.Repo, "alice", "bob", "carol")
+	restful := model.Protocols()[0].Name
+	team, err := h.Repo.CreateTeam("red", "alice")
+	if err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+	if err := h.Repo.InviteToTeam(team.ID, "carol"); err != nil {
+		t.Fatalf("InviteToTeam: %v", err)
+	}
+	if err := h.Repo.JoinTeam(team.ID, "carol"); err != nil {
+		t.Fatalf("JoinTeam: %v", err)
+	}
+	conquer(t, h.Repo, 2, restful, "bob")
+	conquer(t, h.Repo, 3, restful, "carol")
+	if _, err := h.Repo.Conquer(4, restful, "bob", model.FieldLock{Duration: time.Hour}); err != nil {
+		t.Fatalf("Conquer: %v", err)
+	}
+
+	// fields run in order, so the repeated field is already owned
+	outcomes, err := h.Repo.ConquerFields([]int{1, 2, 3, 4, 1}, restful, "alice", model.FieldLock{})
+	if err != nil {
+		t.Fatalf("ConquerFields: %v", err)
+	}
+	if len(outcomes) != 5 {
+		t.Fatalf("got %d outcomes, want 5", len(outcomes))
+	}
+	if outcomes[0].Err != nil || outcomes[0].PreviousOwner != "" {
+		t.Errorf("free field = %+v", outcomes[0])
+	}
+	if outcomes[1].Err != nil || outcomes[1].PreviousOwner != "bob" {
+		t.Errorf("bob's field = %+v, want taken from bob", outcomes[1])
+	}
+	if !errors.Is(outcomes[2].Err, model.ErrTeammateField) {
+		t.Errorf("teammate's field = %+v, want ErrTeammateField", outcomes[2])
+	}
+	var locked *model.FieldLockedError
+	if !errors.As(outcomes[3].Err, &locked) {
+		t.Errorf("locked field = %+v, want FieldLockedError", outcomes[3])
+	}
+	if outcomes[4].Err != nil || outcomes[4].PreviousOwner != "alice" {
+		t.Errorf("repeated field = %+v, want owned by alice", outcomes[4])
+	}
+
+	scores := scoreMap(t, h.Repo)
+	if scores["alice"].ConquerFieldCount != 2 || scores["bob"].ConquerFieldCount != 1 {
+		t.Errorf("scores after batch = %+v, want alice 2 and bob 1", scores)
+	}
+	if fields, err := h.Repo.GetUserConquerField("alice", restful); err != nil || len(fields) != 2 {
+		t.Errorf("alice fields = %v, %v, want 2", fields, err)
+	}
+}
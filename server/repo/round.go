@@ -0,0 +1,133 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zodius/api-war/model"
+)
+
+func (r *repo) GetRound() (model.Round, error) {
+	values, err := r.client.HGetAll(context.Background(), "round").Result()
+	if err != nil {
+		return model.Round{}, err
+	}
+	if len(values) == 0 {
+		return model.Round{}, model.ErrNotFound
+	}
+
+	var fields [4]int64
+	for i, name := range []string{"id", "startAt", "endAt", "freezeAt"} {
+		fields[i], err = strconv.ParseInt(values[name], 10, 64)
+		if err != nil {
+			return model.Round{}, fmt.Errorf("invalid round %s %q: %w", name, values[name], err)
+		}
+	}
+
+	round := model.Round{
+		ID:      int(fields[0]),
+		StartAt: time.UnixMilli(fields[1]),
+		EndAt:   time.UnixMilli(fields[2]),
+		Scoring: model.Scoring(values["scoring"]),
+	}
+	if fields[3] != 0 {
+		round.FreezeAt = time.UnixMilli(fields[3])
+	}
+	return round, nil
+}
+
+func (r *repo) SetRound(round model.Round) error {
+	var freezeAt int64
+	if !round.FreezeAt.IsZero() {
+		freezeAt = round.FreezeAt.UnixMilli()
+	}
+	defer r.holdRanks.reset()
+	return r.client.HSet(context.Background(), "round",
+		"id", round.ID,
+		"startAt", round.StartAt.UnixMilli(),
+		"endAt", round.EndAt.UnixMilli(),
+		"freezeAt", freezeAt,
+		"scoring", string(round.Scoring),
+	).Err()
+}
+
+func (r *repo) FreezeScoreboard(roundID int) error {
+	defer r.holdRanks.reset()
+	return freezeScoreboardScript.Run(context.Background(), r.client,
+		[]string{"frozen:round", "rounds:archived", "round"},
+		typeArgs(roundID, r.now().UnixMilli())...,
+	).Err()
+}
+
+func (r *repo) GetFrozenScoreboard(roundID, offset, limit int) ([]model.Score, error) {
+	if err := r.checkFrozen(roundID); err != nil {
+		return nil, err
+	}
+	return r.scoreboard("frozen:", offset, limit)
+}
+
+func (r *repo) GetFrozenScoreNeighbourhood(roundID int, username string, around int) ([]model.Score, error) {
+	if err := r.checkFrozen(roundID); err != nil {
+		return nil, err
+	}
+	return r.scoreNeighbourhood("frozen:", username, around)
+}
+
+// checkFrozen returns ErrNotFound unless the frozen scoreboard is of roundID
+func (r *repo) checkFrozen(roundID int) error {
+	frozenRound, err := r.client.Get(context.Background(), "frozen:round").Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return model.ErrNotFound
+		}
+		return err
+	}
+	if frozenRound != strconv.Itoa(roundID) {
+		return model.ErrNotFound
+	}
+	return nil
+}
+
+func (r *repo) ArchiveRound(roundID int) error {
+	defer r.holdRanks.reset()
+	return archiveRoundScript.Run(context.Background(), r.client,
+		[]string{"rounds:archived", "round", "map:version", eventLogKey, "rounds:archivedAt"},
+		typeArgs(roundID, r.now().UnixMilli())...,
+	).Err()
+}
+
+func (r *repo) GetArchivedScoreboard(roundID, offset, limit int) ([]model.Score, error) {
+	archived, err := r.client.SIsMember(context.Background(), "rounds:archived", roundID).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !archived {
+		return nil, model.ErrNotFound
+	}
+	return r.scoreboard(fmt.Sprintf("archive:round:%d:", roundID), offset, limit)
+}
+
+func (r *repo) GetArchiveTimes() ([]time.Time, error) {
+	// scored by time, so already in order
+	zrange, err := r.client.ZRangeWithScores(context.Background(), "rounds:archivedAt", 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]time.Time, 0, len(zrange))
+	for _, z := range zrange {
+		result = append(result, time.UnixMilli(int64(z.Score)))
+	}
+	return result, nil
+}
+
+// typeArgs are the script arguments args followed by every conquer type
+func typeArgs(args ...interface{}) []interface{} {
+	for _, protocol := range model.Protocols() {
+		args = append(args, protocol.Name)
+	}
+	return args
+}
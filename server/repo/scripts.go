@@ -0,0 +1,463 @@
+package repo
+
+import (
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zodius/api-war/model"
+)
+
+// recordChangeLua defines recordChange(version, conquerType, fieldID, owner)
+// for scripts that move fields, it appends to map:changes and drops the
+// oldest changes beyond model.MapChangeLimit
+var recordChangeLua = fmt.Sprintf(`
+local function recordChange(version, conquerType, fieldID, owner)
+	redis.call('ZADD', 'map:changes', version, version .. ':' .. conquerType .. ':' .. fieldID .. ':' .. owner)
+	local trim = redis.call('ZCARD', 'map:changes') - %d
+	if trim > 0 then
+		local last = redis.call('ZRANGE', 'map:changes', trim - 1, trim - 1, 'WITHSCORES')
+		redis.call('SET', 'map:changes:floor', last[2])
+		redis.call('ZREMRANGEBYRANK', 'map:changes', 0, trim - 1)
+	end
+end
+`, model.MapChangeLimit)
+
+// recordHistoryLua defines recordHistory(fieldID, conquerType, owner, now)
+// for scripts that move fields, it keeps the last model.FieldHistoryLimit
+// changes of every field and conquer type. The types share a list to keep
+// their changes in order, so it is short enough to scan on every change.
+var recordHistoryLua = fmt.Sprintf(`
+local function recordHistory(fieldID, conquerType, owner, now)
+	local key = 'field:' .. fieldID .. ':history'
+	redis.call('LPUSH', key, now .. ':' .. conquerType .. ':' .. owner)
+	local count, oldest = 0, nil
+	for _, entry in ipairs(redis.call('LRANGE', key, 0, -1)) do
+		if string.match(entry, '^[^:]*:([^:]*):') == conquerType then
+			count = count + 1
+			oldest = entry
+		end
+	end
+	if count > %d then
+		redis.call('LREM', key, -1, oldest)
+	end
+end
+`, model.FieldHistoryLimit)
+
+// updateRankLua defines updateRank(username) for scripts that change
+// score:conquerCount, it moves the user in score:rank behind everyone who
+// reached the same count earlier. The score is formatted in the script since
+// redis converts lua numbers with 14 significant digits.
+const updateRankLua = `
+local function updateRank(username)
+	local count = tonumber(redis.call('ZSCORE', 'score:conquerCount', username) or '0')
+	local seq = redis.call('INCR', 'score:rank:seq') % 4294967296
+	redis.call('ZADD', 'score:rank', string.format('%.17g', count * 4294967296 + 4294967295 - seq), username)
+end
+`
+
+// settleHoldLua defines settleHold(username, now) for scripts that change
+// score:conquerCount, it has to run before the count changes. It adds the
+// time the user held fields since the last settle to score:holdTime, so held
+// time is only computed when a count changes instead of on every tick.
+const settleHoldLua = `
+local function settleHold(username, now)
+	now = tonumber(now)
+	local since = tonumber(redis.call('ZSCORE', 'score:holdTime:since', username) or now)
+	if now < since then
+		return
+	end
+	local count = tonumber(redis.call('ZSCORE', 'score:conquerCount', username) or '0')
+	if count > 0 and now > since then
+		redis.call('ZINCRBY', 'score:holdTime', string.format('%.17g', count * (now - since)), username)
+	end
+	redis.call('ZADD', 'score:holdTime:since', string.format('%.17g', now), username)
+end
+`
+
+// settleHoldScript settles the held time of users whose count is changed
+// outside of a script
+//
+//	ARGV: now in unix ms, usernames...
+var settleHoldScript = redis.NewScript(settleHoldLua + `
+for i = 2, #ARGV do
+	settleHold(ARGV[i], ARGV[1])
+end
+return #ARGV - 1
+`)
+
+// updateRankScript updates score:rank after score:conquerCount was changed
+// outside of a script
+//
+//	ARGV: usernames...
+var updateRankScript = redis.NewScript(updateRankLua + `
+for i = 1, #ARGV do
+	updateRank(ARGV[i])
+end
+return #ARGV
+`)
+
+// conquerScript moves the field to the new owner and updates the bitmaps and
+// scores of both owners in a single step, so a failure can never leave them
+// inconsistent
+//
+//	KEYS: fields:<type>:conquerer, user:<username>:conquerField:<type>,
+//	      score:conquerCount, score:conquerHistory:<type>, map:version,
+//	      events:conquer, field:<type>:<fieldID>:lock, rounds:archived
+//	ARGV: fieldID, username, conquerType, now in unix ms,
+//	      lock in ms after taking a field from its owner, lock in ms after
+//	      taking a free field
+//
+// the previous owner's bitmap key is derived in the script since it is only
+// known after reading the owner hash. A lock holds the number of archived
+// rounds when it was taken, so it ends with its round.
+//
+// returns the previous owner, empty string if the field was free, nothing is
+// changed if the user already owns the field, the unlock time in unix ms if
+// the field is locked, nil if a teammate owns it
+var conquerScript = redis.NewScript(recordChangeLua + recordHistoryLua + settleHoldLua + updateRankLua + `
+local previous = redis.call('HGET', KEYS[1], ARGV[1]) or ''
+if previous == ARGV[2] then
+	return previous
+end
+local archived = redis.call('SCARD', KEYS[8])
+local unlockAt, lockArchived = string.match(redis.call('GET', KEYS[7]) or '', '^(%d+):(%d+)$')
+if unlockAt and tonumber(lockArchived) == archived then
+	return tonumber(unlockAt)
+end
+if previous ~= '' then
+	local team = redis.call('HGET', 'user:' .. ARGV[2], 'team')
+	if team and team == redis.call('HGET', 'user:' .. previous, 'team') then
+		return false
+	end
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('SETBIT', KEYS[2], ARGV[1], 1)
+if previous ~= '' then
+	redis.call('SETBIT', 'user:' .. previous .. ':conquerField:' .. ARGV[3], ARGV[1], 0)
+	settleHold(previous, ARGV[4])
+	redis.call('ZINCRBY', KEYS[3], -1, previous)
+	updateRank(previous)
+end
+settleHold(ARGV[2], ARGV[4])
+redis.call('ZINCRBY', KEYS[3], 1, ARGV[2])
+updateRank(ARGV[2])
+redis.call('ZINCRBY', KEYS[4], 1, ARGV[2])
+recordChange(redis.call('INCR', KEYS[5]), ARGV[3], ARGV[1], ARGV[2])
+recordHistory(ARGV[1], ARGV[3], ARGV[2], ARGV[4])
+redis.call('XADD', KEYS[6], '*', 'action', 'conquer', 'type', ARGV[3], 'field', ARGV[1],
+	'user', ARGV[2], 'previous', previous, 'time', ARGV[4])
+local lock = tonumber(ARGV[5])
+if previous == '' then
+	lock = tonumber(ARGV[6])
+end
+if lock > 0 then
+	redis.call('SET', KEYS[7], string.format('%d:%d', tonumber(ARGV[4]) + lock, archived), 'PX', lock)
+end
+return previous
+`)
+
+// pruneTokensLua drops the tokens which expired by now from a user's index,
+// the index and its expiry zset live as long as the newest token
+//
+//	index: user:<username>:tokens, expiry: user:<username>:tokens:expiry
+const pruneTokensLua = `
+local function pruneTokens(index, expiry, now, ttl)
+	local expired = redis.call('ZRANGEBYSCORE', expiry, '-inf', now)
+	for _, token in ipairs(expired) do
+		redis.call('HDEL', index, token .. ':created', token .. ':lastUsed')
+	end
+	redis.call('ZREMRANGEBYSCORE', expiry, '-inf', now)
+	redis.call('PEXPIRE', index, ttl)
+	redis.call('PEXPIRE', expiry, ttl)
+end
+`
+
+// createTokenScript stores a new token and adds it to the owner's index
+//
+//	KEYS: token:<token>, user:<username>:tokens, user:<username>:tokens:expiry
+//	ARGV: ttl in ms, token, username, now in unix ms
+var createTokenScript = redis.NewScript(pruneTokensLua + `
+local ttl, now = tonumber(ARGV[1]), tonumber(ARGV[4])
+redis.call('SET', KEYS[1], ARGV[3], 'PX', ttl)
+redis.call('HSET', KEYS[2], ARGV[2] .. ':created', now, ARGV[2] .. ':lastUsed', now)
+redis.call('ZADD', KEYS[3], now + ttl, ARGV[2])
+pruneTokens(KEYS[2], KEYS[3], now, ttl)
+return 1
+`)
+
+// touchTokenScript resolves a token and extends its lifetime
+//
+//	KEYS: token:<token>
+//	ARGV: ttl in ms, token, now in unix ms
+//
+// returns the username, nil if the token doesn't exist
+var touchTokenScript = redis.NewScript(pruneTokensLua + `
+local username = redis.call('GET', KEYS[1])
+if not username then
+	return false
+end
+local ttl, now = tonumber(ARGV[1]), tonumber(ARGV[3])
+local index = 'user:' .. username .. ':tokens'
+redis.call('PEXPIRE', KEYS[1], ttl)
+redis.call('HSET', index, ARGV[2] .. ':lastUsed', now)
+redis.call('ZADD', index .. ':expiry', now + ttl, ARGV[2])
+pruneTokens(index, index .. ':expiry', now, ttl)
+return username
+`)
+
+// deleteTokenScript revokes a token and removes it from the owner's index
+//
+//	KEYS: token:<token>
+//	ARGV: token
+var deleteTokenScript = redis.NewScript(`
+local username = redis.call('GET', KEYS[1])
+if not username then
+	return 0
+end
+local index = 'user:' .. username .. ':tokens'
+redis.call('DEL', KEYS[1])
+redis.call('HDEL', index, ARGV[1] .. ':created', ARGV[1] .. ':lastUsed')
+redis.call('ZREM', index .. ':expiry', ARGV[1])
+return 1
+`)
+
+// deleteUserTokensScript revokes every token in a user's index
+//
+//	KEYS: user:<username>:tokens, user:<username>:tokens:expiry
+var deleteUserTokensScript = redis.NewScript(`
+local fields = redis.call('HKEYS', KEYS[1])
+for _, field in ipairs(fields) do
+	local token = string.match(field, '^(.*):created$')
+	if token then
+		redis.call('DEL', 'token:' .. token)
+	end
+end
+redis.call('DEL', KEYS[1], KEYS[2])
+return #fields
+`)
+
+// freezeScoreboardScript copies the scoreboard of the running round, held
+// time is settled up to the freeze time of the round
+//
+//	KEYS: frozen:round, rounds:archived, round
+//	ARGV: roundID, now in unix ms, conquer types...
+//
+// returns 1 if the scoreboard was copied, 0 if it is already frozen or the
+// round was archived
+var freezeScoreboardScript = redis.NewScript(settleHoldLua + `
+if redis.call('GET', KEYS[1]) == ARGV[1] or redis.call('SISMEMBER', KEYS[2], ARGV[1]) == 1 then
+	return 0
+end
+local function freeze(key)
+	redis.call('DEL', 'frozen:' .. key)
+	redis.call('COPY', key, 'frozen:' .. key)
+end
+local at = tonumber(ARGV[2])
+if redis.call('HGET', KEYS[3], 'id') == ARGV[1] then
+	local freezeAt = tonumber(redis.call('HGET', KEYS[3], 'freezeAt') or '0')
+	if freezeAt > 0 and freezeAt < at then
+		at = freezeAt
+	end
+end
+for _, username in ipairs(redis.call('ZRANGE', 'users', 0, -1)) do
+	settleHold(username, at)
+end
+freeze('score:conquerCount')
+freeze('score:rank')
+freeze('score:holdTime')
+for i = 3, #ARGV do
+	freeze('score:conquerHistory:' .. ARGV[i])
+end
+redis.call('SET', KEYS[1], ARGV[1])
+return 1
+`)
+
+// archiveRoundScript renames the map and scoreboard keys into the archive of
+// a round and resets every user to an empty holding, held time is settled up
+// to the end of the round
+//
+//	KEYS: rounds:archived, round, map:version, events:conquer, rounds:archivedAt
+//	ARGV: roundID, now in unix ms, conquer types...
+//
+// field histories are kept, a holding ends at the latest when its round was
+// archived
+//
+// returns 1 if the round was archived, 0 if it was archived before
+var archiveRoundScript = redis.NewScript(settleHoldLua + updateRankLua + `
+if redis.call('SADD', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+local prefix = 'archive:round:' .. ARGV[1] .. ':'
+local function archive(key)
+	if redis.call('EXISTS', key) == 1 then
+		redis.call('RENAME', key, prefix .. key)
+	end
+end
+local at = tonumber(ARGV[2])
+if redis.call('HGET', KEYS[2], 'id') == ARGV[1] then
+	redis.call('COPY', KEYS[2], prefix .. 'round')
+	local endAt = tonumber(redis.call('HGET', KEYS[2], 'endAt') or '0')
+	if endAt > 0 and endAt < at then
+		at = endAt
+	end
+end
+for _, username in ipairs(redis.call('ZRANGE', 'users', 0, -1)) do
+	settleHold(username, at)
+end
+archive('score:conquerCount')
+archive('score:rank')
+archive('score:holdTime')
+redis.call('DEL', 'score:holdTime:since')
+redis.call('DEL', 'frozen:round', 'frozen:score:conquerCount', 'frozen:score:rank', 'frozen:score:holdTime')
+for i = 3, #ARGV do
+	archive('fields:' .. ARGV[i] .. ':conquerer')
+	archive('score:conquerHistory:' .. ARGV[i])
+	redis.call('DEL', 'frozen:score:conquerHistory:' .. ARGV[i])
+end
+local users = redis.call('ZRANGE', 'users', 0, -1)
+for _, username in ipairs(users) do
+	redis.call('ZADD', 'score:conquerCount', 0, username)
+	updateRank(username)
+	for i = 3, #ARGV do
+		redis.call('ZADD', 'score:conquerHistory:' .. ARGV[i], 0, username)
+		redis.call('DEL', 'user:' .. username .. ':conquerField:' .. ARGV[i])
+	end
+end
+-- changes before the reset can't be applied to the new map
+local version = redis.call('INCR', KEYS[3])
+redis.call('DEL', 'map:changes')
+redis.call('SET', 'map:changes:floor', version)
+redis.call('XADD', KEYS[4], '*', 'action', 'reset', 'round', ARGV[1], 'time', ARGV[2])
+redis.call('ZADD', KEYS[5], ARGV[2], ARGV[1])
+return 1
+`)
+
+// setFieldOwnerScript moves a field like conquerScript without counting a
+// conquer in the history
+//
+//	KEYS: fields:<type>:conquerer, score:conquerCount, map:version, events:conquer
+//	ARGV: fieldID, username or empty string to free the field, conquerType,
+//	      now in unix ms
+//
+// returns the previous owner
+var setFieldOwnerScript = redis.NewScript(recordChangeLua + recordHistoryLua + settleHoldLua + updateRankLua + `
+local previous = redis.call('HGET', KEYS[1], ARGV[1]) or ''
+if previous == ARGV[2] then
+	return previous
+end
+if previous ~= '' then
+	redis.call('SETBIT', 'user:' .. previous .. ':conquerField:' .. ARGV[3], ARGV[1], 0)
+	settleHold(previous, ARGV[4])
+	redis.call('ZINCRBY', KEYS[2], -1, previous)
+	updateRank(previous)
+end
+if ARGV[2] == '' then
+	redis.call('HDEL', KEYS[1], ARGV[1])
+else
+	redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+	redis.call('SETBIT', 'user:' .. ARGV[2] .. ':conquerField:' .. ARGV[3], ARGV[1], 1)
+	settleHold(ARGV[2], ARGV[4])
+	redis.call('ZINCRBY', KEYS[2], 1, ARGV[2])
+	updateRank(ARGV[2])
+end
+recordChange(redis.call('INCR', KEYS[3]), ARGV[3], ARGV[1], ARGV[2])
+recordHistory(ARGV[1], ARGV[3], ARGV[2], ARGV[4])
+redis.call('XADD', KEYS[4], '*', 'action', 'set', 'type', ARGV[3], 'field', ARGV[1],
+	'user', ARGV[2], 'previous', previous, 'time', ARGV[4])
+return previous
+`)
+
+// wipeUserFieldsScript frees every field in the bitmaps of a user
+//
+//	KEYS: score:conquerCount, map:version, events:conquer
+//	ARGV: username, now in unix ms, conquer types...
+//
+// returns the version of the changes, 0 if nothing was freed, followed by
+// the conquer type and id of every freed field
+var wipeUserFieldsScript = redis.NewScript(recordChangeLua + recordHistoryLua + settleHoldLua + updateRankLua + `
+local removed = 0
+local freed = {}
+-- every freed field is recorded under the same version
+local version
+for i = 3, #ARGV do
+	local ownerKey = 'fields:' .. ARGV[i] .. ':conquerer'
+	local bitmapKey = 'user:' .. ARGV[1] .. ':conquerField:' .. ARGV[i]
+	local bitmap = redis.call('GET', bitmapKey) or ''
+	for index = 1, #bitmap do
+		local byte = string.byte(bitmap, index)
+		-- redis bitmaps are big endian within a byte
+		for bit = 0, 7 do
+			if math.floor(byte / 2 ^ (7 - bit)) % 2 == 1 then
+				local fieldID = (index - 1) * 8 + bit
+				if redis.call('HGET', ownerKey, fieldID) == ARGV[1] then
+					redis.call('HDEL', ownerKey, fieldID)
+					removed = removed + 1
+					freed[#freed + 1] = ARGV[i]
+					freed[#freed + 1] = fieldID
+					version = version or redis.call('INCR', KEYS[2])
+					recordChange(version, ARGV[i], fieldID, '')
+					recordHistory(fieldID, ARGV[i], '', ARGV[2])
+				end
+			end
+		end
+	end
+	redis.call('DEL', bitmapKey)
+end
+local count = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if count then
+	settleHold(ARGV[1], ARGV[2])
+	redis.call('ZADD', KEYS[1], 0, ARGV[1])
+	if tonumber(count) ~= 0 then
+		updateRank(ARGV[1])
+	end
+end
+if removed > 0 then
+	redis.call('XADD', KEYS[3], '*', 'action', 'wipe', 'user', ARGV[1], 'time', ARGV[2])
+end
+table.insert(freed, 1, version or 0)
+return freed
+`)
+
+// createTeamScript creates a team with its first member
+//
+//	KEYS: teams:name, teamcount, user:<username>
+//	ARGV: name, username
+//
+// returns the team id, 0 if the user is in a team, -1 if the name is taken
+var createTeamScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[3], 'team') == 1 then
+	return 0
+end
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 1 then
+	return -1
+end
+local id = redis.call('INCR', KEYS[2])
+redis.call('HSET', KEYS[1], ARGV[1], id)
+redis.call('HSET', 'team:' .. id, 'name', ARGV[1])
+redis.call('RPUSH', 'team:' .. id .. ':members', ARGV[2])
+redis.call('HSET', KEYS[3], 'team', id)
+return id
+`)
+
+// joinTeamScript moves an invited user into a team
+//
+//	KEYS: team:<id>:members, team:<id>:invites, user:<username>
+//	ARGV: teamID, username, max team size
+//
+// returns 1 if the user joined, 0 if the user is in a team, -1 without an
+// invite, -2 if the team is full
+var joinTeamScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[3], 'team') == 1 then
+	return 0
+end
+if redis.call('SISMEMBER', KEYS[2], ARGV[2]) == 0 then
+	return -1
+end
+if redis.call('LLEN', KEYS[1]) >= tonumber(ARGV[3]) then
+	return -2
+end
+redis.call('RPUSH', KEYS[1], ARGV[2])
+redis.call('SREM', KEYS[2], ARGV[2])
+redis.call('HSET', KEYS[3], 'team', ARGV[1])
+return 1
+`)
@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zodius/api-war/model"
+)
+
+// snapshotScanCount is the HSCAN hint when reading whole owner hashes
+const snapshotScanCount = 10000
+
+func (r *repo) GetMapVersion() (uint64, error) {
+	return optionalUint(r.client.Get(context.Background(), "map:version"))
+}
+
+func (r *repo) GetMapSnapshot(fieldCount int) (model.MapSnapshot, error) {
+	// read the version first, conquers during the scan only make the
+	// snapshot newer than its version
+	version, err := r.GetMapVersion()
+	if err != nil {
+		return model.MapSnapshot{}, err
+	}
+
+	users, err := r.GetUserList()
+	if err != nil {
+		return model.MapSnapshot{}, err
+	}
+	userIDs := make(map[string]uint32, len(users))
+	for _, user := range users {
+		userIDs[user.Username] = uint32(user.ID)
+	}
+
+	snapshot := model.MapSnapshot{
+		Version:    version,
+		FieldCount: fieldCount,
+		Owners:     make(map[string][]uint32),
+		Users:      users,
+	}
+	for _, protocol := range model.Protocols() {
+		owners := make([]uint32, fieldCount)
+		iter := r.client.HScan(context.Background(), fmt.Sprintf("fields:%s:conquerer", protocol.Name), 0, "", snapshotScanCount).Iterator()
+		for iter.Next(context.Background()) {
+			field := iter.Val()
+			if !iter.Next(context.Background()) {
+				break
+			}
+			fieldID, err := strconv.Atoi(field)
+			if err != nil || fieldID < 1 || fieldID > fieldCount {
+				continue
+			}
+			owners[fieldID-1] = userIDs[iter.Val()]
+		}
+		if err := iter.Err(); err != nil {
+			return model.MapSnapshot{}, err
+		}
+		snapshot.Owners[protocol.Name] = owners
+	}
+	return snapshot, nil
+}
+
+func (r *repo) GetMapChanges(since uint64) (model.MapChanges, error) {
+	var version, floor *redis.StringCmd
+	var changes *redis.ZSliceCmd
+	// read in a transaction so the changes end exactly at version
+	if _, err := r.client.TxPipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		version = pipe.Get(context.Background(), "map:version")
+		floor = pipe.Get(context.Background(), "map:changes:floor")
+		changes = pipe.ZRangeByScoreWithScores(context.Background(), "map:changes", &redis.ZRangeBy{
+			Min: fmt.Sprintf("(%d", since),
+			Max: "+inf",
+		})
+		return nil
+	}); err != nil && !errors.Is(err, redis.Nil) {
+		return model.MapChanges{}, err
+	}
+
+	current, err := optionalUint(version)
+	if err != nil {
+		return model.MapChanges{}, err
+	}
+	oldest, err := optionalUint(floor)
+	if err != nil {
+		return model.MapChanges{}, err
+	}
+	if since < oldest || since > current {
+		return model.MapChanges{}, &model.ResyncRequiredError{Version: current}
+	}
+
+	result := model.MapChanges{
+		Version: current,
+		Changes: make([]model.MapChange, 0, len(changes.Val())),
+	}
+	for _, z := range changes.Val() {
+		// <version>:<type>:<fieldID>:<owner>, usernames may contain colons
+		parts := strings.SplitN(z.Member.(string), ":", 4)
+		if len(parts) != 4 {
+			return model.MapChanges{}, fmt.Errorf("invalid map change %q", z.Member)
+		}
+		fieldID, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return model.MapChanges{}, fmt.Errorf("invalid map change %q: %w", z.Member, err)
+		}
+		result.Changes = append(result.Changes, model.MapChange{
+			Version:     uint64(z.Score),
+			FieldID:     fieldID,
+			ConquerType: parts[1],
+			Owner:       parts[3],
+		})
+	}
+	return result, nil
+}
+
+// optionalUint reads a counter which doesn't exist before its first increment
+func optionalUint(cmd *redis.StringCmd) (uint64, error) {
+	value, err := cmd.Uint64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return value, err
+}
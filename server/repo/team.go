@@ -0,0 +1,209 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zodius/api-war/model"
+)
+
+func (r *repo) CreateTeam(name, username string) (model.Team, error) {
+	teamID, err := createTeamScript.Run(context.Background(), r.client,
+		[]string{"teams:name", "teamcount", fmt.Sprintf("user:%s", username)},
+		name, username,
+	).Int()
+	if err != nil {
+		return model.Team{}, err
+	}
+	switch teamID {
+	case 0:
+		return model.Team{}, model.ErrAlreadyInTeam
+	case -1:
+		return model.Team{}, model.ErrTeamExist
+	}
+	return model.Team{
+		ID:      teamID,
+		Name:    name,
+		Members: []string{username},
+		Invites: []string{},
+	}, nil
+}
+
+func (r *repo) GetTeam(teamID int) (model.Team, error) {
+	teams, err := r.teams(teamID)
+	if err != nil {
+		return model.Team{}, err
+	}
+	if len(teams) == 0 {
+		return model.Team{}, model.ErrNotFound
+	}
+	return teams[0], nil
+}
+
+func (r *repo) GetTeams() ([]model.Team, error) {
+	count, err := r.client.Get(context.Background(), "teamcount").Int()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	// teams are never deleted, so the ids are 1 to teamcount
+	teamIDs := make([]int, 0, count)
+	for teamID := 1; teamID <= count; teamID++ {
+		teamIDs = append(teamIDs, teamID)
+	}
+	return r.teams(teamIDs...)
+}
+
+// teams reads the given teams in one round trip, unknown ids are skipped
+func (r *repo) teams(teamIDs ...int) ([]model.Team, error) {
+	names := make([]*redis.StringCmd, len(teamIDs))
+	members := make([]*redis.StringSliceCmd, len(teamIDs))
+	invites := make([]*redis.StringSliceCmd, len(teamIDs))
+	if _, err := r.client.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		for i, teamID := range teamIDs {
+			names[i] = pipe.HGet(context.Background(), fmt.Sprintf("team:%d", teamID), "name")
+			members[i] = pipe.LRange(context.Background(), fmt.Sprintf("team:%d:members", teamID), 0, -1)
+			invites[i] = pipe.SMembers(context.Background(), fmt.Sprintf("team:%d:invites", teamID))
+		}
+		return nil
+	}); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	teams := make([]model.Team, 0, len(teamIDs))
+	for i, teamID := range teamIDs {
+		name, err := names[i].Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			return nil, err
+		}
+		team := model.Team{
+			ID:      teamID,
+			Name:    name,
+			Members: members[i].Val(),
+			Invites: invites[i].Val(),
+		}
+		sort.Strings(team.Invites)
+		teams = append(teams, team)
+	}
+	return teams, nil
+}
+
+func (r *repo) InviteToTeam(teamID int, username string) error {
+	exists, err := r.client.Exists(context.Background(),
+		fmt.Sprintf("team:%d", teamID),
+		fmt.Sprintf("user:%s", username),
+	).Result()
+	if err != nil {
+		return err
+	}
+	if exists != 2 {
+		return model.ErrNotFound
+	}
+	return r.client.SAdd(context.Background(), fmt.Sprintf("team:%d:invites", teamID), username).Err()
+}
+
+func (r *repo) JoinTeam(teamID int, username string) error {
+	result, err := joinTeamScript.Run(context.Background(), r.client,
+		[]string{
+			fmt.Sprintf("team:%d:members", teamID),
+			fmt.Sprintf("team:%d:invites", teamID),
+			fmt.Sprintf("user:%s", username),
+		},
+		teamID, username, model.MaxTeamSize,
+	).Int()
+	if err != nil {
+		return err
+	}
+	switch result {
+	case 0:
+		return model.ErrAlreadyInTeam
+	case -1:
+		return model.ErrNotInvited
+	case -2:
+		return model.ErrTeamFull
+	}
+	return nil
+}
+
+func (r *repo) GetTeamScores() ([]model.TeamScore, error) {
+	return r.teamScores("")
+}
+
+func (r *repo) GetFrozenTeamScores(roundID int) ([]model.TeamScore, error) {
+	if err := r.checkFrozen(roundID); err != nil {
+		return nil, err
+	}
+	return r.teamScores("frozen:")
+}
+
+// teamScores sums the member scores under prefix for every team
+func (r *repo) teamScores(prefix string) ([]model.TeamScore, error) {
+	teams, err := r.GetTeams()
+	if err != nil {
+		return nil, err
+	}
+
+	_, endAt, err := r.scoringRound(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var members []string
+	for _, team := range teams {
+		members = append(members, team.Members...)
+	}
+	held, err := r.holdTimes(prefix, members, endAt)
+	if err != nil {
+		return nil, err
+	}
+
+	protocols := model.Protocols()
+	counts := make([]*redis.FloatSliceCmd, len(teams))
+	histories := make([][]*redis.FloatSliceCmd, len(teams))
+	if _, err := r.client.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		for i, team := range teams {
+			counts[i] = pipe.ZMScore(context.Background(), prefix+"score:conquerCount", team.Members...)
+			histories[i] = make([]*redis.FloatSliceCmd, len(protocols))
+			for j, protocol := range protocols {
+				histories[i][j] = pipe.ZMScore(context.Background(),
+					fmt.Sprintf("%sscore:conquerHistory:%s", prefix, protocol.Name),
+					team.Members...,
+				)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	scores := make([]model.TeamScore, 0, len(teams))
+	member := 0
+	for i, team := range teams {
+		score := model.TeamScore{
+			TeamID:              team.ID,
+			Name:                team.Name,
+			Members:             team.Members,
+			ConquerHistoryCount: make(map[string]int),
+		}
+		for _, count := range counts[i].Val() {
+			score.ConquerFieldCount += int(count)
+		}
+		for j, protocol := range protocols {
+			for _, count := range histories[i][j].Val() {
+				score.ConquerHistoryCount[protocol.ScoreColumn] += int(count)
+			}
+		}
+		var teamHeld int64
+		for range team.Members {
+			teamHeld += held[member]
+			member++
+		}
+		score.HoldPoints = int(teamHeld / 1000)
+		scores = append(scores, score)
+	}
+	return scores, nil
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/zodius/api-war/model"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+func (s *service) BootstrapAdmin(username, password string) error {
+	if _, err := s.repo.GetUser(username); err != nil {
+		if !errors.Is(err, model.ErrNotFound) {
+			return err
+		}
+		// another backend may create it at the same time
+		if err := s.Register(username, password); err != nil && !errors.Is(err, model.ErrUserExist) {
+			return err
+		}
+	}
+	return s.repo.SetRole(username, model.RoleAdmin)
+}
+
+// admin resolves the token to an admin username
+func (s *service) admin(token string) (string, error) {
+	username, err := s.repo.GetTokenUsername(token)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return "", model.ErrUnauthorized
+		}
+		return "", err
+	}
+	user, err := s.repo.GetUser(username)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return "", model.ErrUnauthorized
+		}
+		return "", err
+	}
+	if !user.IsAdmin() {
+		return "", model.ErrForbidden
+	}
+	return username, nil
+}
+
+// audit records an admin action before it runs, an action whose audit entry
+// can't be written is not run. Every admin call is audited, reads included.
+func (s *service) audit(admin, action, target, detail string) error {
+	return s.repo.AddAuditEntry(model.AuditEntry{
+		Time:   s.now(),
+		Admin:  admin,
+		Action: action,
+		Target: target,
+		Detail: detail,
+	})
+}
+
+func (s *service) AdminListUsers(token string) ([]model.UserStats, error) {
+	admin, err := s.admin(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.audit(admin, "list_users", "", ""); err != nil {
+		return nil, err
+	}
+	return s.repo.GetUserStats()
+}
+
+func (s *service) AdminGetUserSessions(token, username string) ([]model.Session, error) {
+	admin, err := s.admin(token)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetUser(username); err != nil {
+		return nil, err
+	}
+	if err := s.audit(admin, "view_sessions", username, ""); err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.repo.GetUserTokens(username)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].ID = sessionID(sessions[i].Token)
+	}
+	return sessions, nil
+}
+
+func (s *service) AdminBanUser(token, username string) error {
+	admin, err := s.admin(token)
+	if err != nil {
+		return err
+	}
+	if _, err := s.repo.GetUser(username); err != nil {
+		return err
+	}
+	if err := s.audit(admin, "ban", username, ""); err != nil {
+		return err
+	}
+
+	if err := s.repo.SetBanned(username, true); err != nil {
+		return err
+	}
+	// kick the user out of every session
+	return s.repo.DeleteUserTokens(username)
+}
+
+func (s *service) AdminUnbanUser(token, username string) error {
+	admin, err := s.admin(token)
+	if err != nil {
+		return err
+	}
+	if _, err := s.repo.GetUser(username); err != nil {
+		return err
+	}
+	if err := s.audit(admin, "unban", username, ""); err != nil {
+		return err
+	}
+	return s.repo.SetBanned(username, false)
+}
+
+func (s *service) AdminSetFieldOwner(token string, fieldID int, conquerType, username string) error {
+	admin, err := s.admin(token)
+	if err != nil {
+		return err
+	}
+	if _, ok := model.GetProtocol(conquerType); !ok {
+		return model.ErrUnknownProtocol
+	}
+	if err := s.checkField(fieldID); err != nil {
+		return err
+	}
+	if username != "" {
+		if _, err := s.repo.GetUser(username); err != nil {
+			return err
+		}
+	}
+	current, err := s.fieldOwner(fieldID, conquerType)
+	if err != nil {
+		return err
+	}
+	if err := s.audit(admin, "set_field_owner", fmt.Sprintf("%s:%d", conquerType, fieldID),
+		fmt.Sprintf("%q -> %q", current, username)); err != nil {
+		return err
+	}
+
+	previousOwner, err := s.repo.SetFieldOwner(fieldID, conquerType, username)
+	if err != nil {
+		return err
+	}
+	if previousOwner != username {
+		_ = s.broker.Publish(model.ConquerEvent{
+			FieldID:     fieldID,
+			ConquerType: conquerType,
+			Owner:       username,
+		})
+	}
+	return nil
+}
+
+// fieldOwner reads the owner of a field for the audit trail
+func (s *service) fieldOwner(fieldID int, conquerType string) (string, error) {
+	fields, err := s.repo.GetMap(fieldID, fieldID)
+	if err != nil {
+		return "", err
+	}
+	for _, field := range fields.Fields {
+		for _, owner := range field.Conquerer {
+			if field.FieldID == fieldID && owner.ConquerType == conquerType {
+				return owner.Owner, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+func (s *service) AdminWipeUser(token, username string) error {
+	admin, err := s.admin(token)
+	if err != nil {
+		return err
+	}
+	if _, err := s.repo.GetUser(username); err != nil {
+		return err
+	}
+	if err := s.audit(admin, "wipe", username, ""); err != nil {
+		return err
+	}
+
+	freed, err := s.repo.WipeUserFields(username)
+	if err != nil {
+		return err
+	}
+	for _, change := range freed {
+		_ = s.broker.Publish(model.ConquerEvent{
+			FieldID:     change.FieldID,
+			ConquerType: change.ConquerType,
+		})
+	}
+	return nil
+}
+
+func (s *service) AdminGetRound(token string) (model.RoundStatus, error) {
+	admin, err := s.admin(token)
+	if err != nil {
+		return model.RoundStatus{}, err
+	}
+	if err := s.audit(admin, "view_round", "", ""); err != nil {
+		return model.RoundStatus{}, err
+	}
+	return s.GetRound()
+}
+
+func (s *service) AdminSetRound(token string, round model.Round) (model.Round, error) {
+	admin, err := s.admin(token)
+	if err != nil {
+		return model.Round{}, err
+	}
+
+	round, archive, err := s.nextRound(round)
+	if err != nil {
+		return model.Round{}, err
+	}
+	detail := fmt.Sprintf("start %s, end %s", round.StartAt.Format(time.RFC3339), round.EndAt.Format(time.RFC3339))
+	if !round.FreezeAt.IsZero() {
+		detail += ", freeze " + round.FreezeAt.Format(time.RFC3339)
+	}
+	detail += ", scoring " + string(round.Scoring)
+	if err := s.audit(admin, "set_round", strconv.Itoa(round.ID), detail); err != nil {
+		return model.Round{}, err
+	}
+	return round, s.applyRound(round, archive)
+}
+
+func (s *service) AdminGetAuditLog(token string, limit int) ([]model.AuditEntry, error) {
+	admin, err := s.admin(token)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	if limit > maxAuditLimit {
+		limit = maxAuditLimit
+	}
+	if err := s.audit(admin, "view_audit_log", "", strconv.Itoa(limit)); err != nil {
+		return nil, err
+	}
+	return s.repo.GetAuditLog(limit)
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zodius/api-war/broker"
+	"github.com/zodius/api-war/limiter"
+	"github.com/zodius/api-war/model"
+	"github.com/zodius/api-war/repo/memory"
+)
+
+func TestAdminAccess(t *testing.T) {
+	s := NewService(memory.NewRepo(), broker.NewMemoryBroker(), limiter.NewMemoryLimiter(), testMap)
+
+	if err := s.BootstrapAdmin("root", "rootpass"); err != nil {
+		t.Fatalf("BootstrapAdmin: %v", err)
+	}
+	if err := s.Register("alice", "alicepass"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	adminToken, err := s.Login("root", "rootpass")
+	if err != nil {
+		t.Fatalf("Login admin: %v", err)
+	}
+	playerToken, err := s.Login("alice", "alicepass")
+	if err != nil {
+		t.Fatalf("Login player: %v", err)
+	}
+
+	if _, err := s.AdminListUsers(playerToken); !errors.Is(err, model.ErrForbidden) {
+		t.Errorf("AdminListUsers as player: err = %v, want ErrForbidden", err)
+	}
+	if _, err := s.AdminListUsers("invalid"); !errors.Is(err, model.ErrUnauthorized) {
+		t.Errorf("AdminListUsers with invalid token: err = %v, want ErrUnauthorized", err)
+	}
+	if err := s.AdminBanUser(adminToken, "nobody"); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("AdminBanUser unknown user: err = %v, want ErrNotFound", err)
+	}
+
+	// banning kicks the user out and blocks login until unbanned
+	if err := s.AdminBanUser(adminToken, "alice"); err != nil {
+		t.Fatalf("AdminBanUser: %v", err)
+	}
+	if _, err := s.GetMe(playerToken); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("token of banned user still valid, err = %v", err)
+	}
+	if _, err := s.Login("alice", "alicepass"); !errors.Is(err, model.ErrBanned) {
+		t.Errorf("Login banned user: err = %v, want ErrBanned", err)
+	}
+	if err := s.AdminUnbanUser(adminToken, "alice"); err != nil {
+		t.Fatalf("AdminUnbanUser: %v", err)
+	}
+	if _, err := s.Login("alice", "alicepass"); err != nil {
+		t.Errorf("Login after unban: %v", err)
+	}
+
+	entries, err := s.AdminGetAuditLog(adminToken, 0)
+	if err != nil {
+		t.Fatalf("AdminGetAuditLog: %v", err)
+	}
+	// the read of the log is audited before it runs
+	if len(entries) != 3 || entries[0].Action != "view_audit_log" || entries[1].Action != "unban" || entries[2].Action != "ban" {
+		t.Fatalf("audit log = %+v, want view_audit_log, unban and ban", entries)
+	}
+	if entries[1].Admin != "root" || entries[1].Target != "alice" {
+		t.Errorf("audit entry = %+v", entries[1])
+	}
+
+	// bootstrapping an existing user only grants the role
+	if err := s.BootstrapAdmin("alice", "ignored"); err != nil {
+		t.Fatalf("BootstrapAdmin existing user: %v", err)
+	}
+	if _, err := s.Login("alice", "alicepass"); err != nil {
+		t.Errorf("BootstrapAdmin changed the password: %v", err)
+	}
+}
+
+func TestAdminWipeUser(t *testing.T) {
+	if _, ok := model.GetProtocol(testConquerType); !ok {
+		model.RegisterProtocol(model.Protocol{Name: testConquerType})
+	}
+
+	repo := memory.NewRepo()
+	b := broker.NewMemoryBroker()
+	s := NewService(repo, b, limiter.NewMemoryLimiter(), testMap)
+
+	if err := s.BootstrapAdmin("root", "rootpass"); err != nil {
+		t.Fatalf("BootstrapAdmin: %v", err)
+	}
+	if err := repo.CreateUser("alice", "password"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	adminToken, err := s.Login("root", "rootpass")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	for _, fieldID := range []int{4, 7} {
+		if _, err := repo.Conquer(fieldID, testConquerType, "alice", model.FieldLock{}); err != nil {
+			t.Fatalf("Conquer: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := b.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if err := s.AdminWipeUser(adminToken, "alice"); err != nil {
+		t.Fatalf("AdminWipeUser: %v", err)
+	}
+
+	// every freed field is published
+	freed := make(map[int]bool)
+	for len(freed) < 2 {
+		select {
+		case event := <-events:
+			if event.Owner != "" || event.ConquerType != testConquerType {
+				t.Errorf("event = %+v, want field freed", event)
+			}
+			freed[event.FieldID] = true
+		case <-time.After(time.Second):
+			t.Fatalf("freed fields published = %v, want 4 and 7", freed)
+		}
+	}
+	if !freed[4] || !freed[7] {
+		t.Errorf("freed fields published = %v, want 4 and 7", freed)
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zodius/api-war/broker"
+	"github.com/zodius/api-war/limiter"
+	"github.com/zodius/api-war/model"
+	"github.com/zodius/api-war/repo/memory"
+)
+
+func TestConquerFields(t *testing.T) {
+	// a protocol of its own, the limit would break other tests
+	const conquerType = "batch"
+	if _, ok := model.GetProtocol(conquerType); !ok {
+		model.RegisterProtocol(model.Protocol{
+			Name:      conquerType,
+			RateLimit: model.RateLimit{Rate: 0.001, Burst: 4},
+		})
+	}
+
+	repo := memory.NewRepo()
+	mapConfig := testMap
+	mapConfig.ConquerBatchSize = 3
+	s := NewService(repo, broker.NewMemoryBroker(), limiter.NewMemoryLimiter(), mapConfig)
+
+	for _, username := range []string{"alice", "bob"} {
+		if err := repo.CreateUser(username, "password"); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+	token, err := repo.CreateToken("alice")
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	if _, err := repo.Conquer(2, conquerType, "bob", model.FieldLock{Duration: time.Hour}); err != nil {
+		t.Fatalf("Conquer: %v", err)
+	}
+
+	if _, err := s.ConquerFields(token, nil, conquerType); !errors.Is(err, model.ErrInvalidBatch) {
+		t.Errorf("empty batch: got %v, want ErrInvalidBatch", err)
+	}
+	if _, err := s.ConquerFields(token, []int{1, 2, 3, 4}, conquerType); !errors.Is(err, model.ErrInvalidBatch) {
+		t.Errorf("oversized batch: got %v, want ErrInvalidBatch", err)
+	}
+
+	items, err := s.ConquerFields(token, []int{1, 2, 101}, conquerType)
+	if err != nil {
+		t.Fatalf("ConquerFields: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("got %d items, want 3", len(items))
+	}
+	if items[0].Err != nil || items[0].FieldID != 1 || items[0].NewOwner != "alice" || items[0].PointsAwarded != 1 {
+		t.Errorf("free field = %+v, want conquered", items[0])
+	}
+	if !errors.Is(items[1].Err, model.ErrFieldLocked) || items[1].FieldID != 2 {
+		t.Errorf("locked field = %+v, want ErrFieldLocked", items[1])
+	}
+	if !errors.Is(items[2].Err, model.ErrFieldOutOfRange) || items[2].FieldID != 101 {
+		t.Errorf("field out of range = %+v, want ErrFieldOutOfRange", items[2])
+	}
+
+	// every item costs a request, one of the burst of four is left
+	items, err = s.ConquerFields(token, []int{1}, conquerType)
+	if err != nil || len(items) != 1 || !items[0].WasAlreadyOwned {
+		t.Errorf("conquer of an owned field = %+v, %v, want already owned", items, err)
+	}
+	if _, err := s.ConquerFields(token, []int{3}, conquerType); !errors.Is(err, model.ErrRateLimited) {
+		t.Errorf("batch over the rate limit: got %v, want ErrRateLimited", err)
+	}
+}
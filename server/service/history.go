@@ -0,0 +1,55 @@
+package service
+
+import (
+	"sort"
+
+	"github.com/zodius/api-war/model"
+)
+
+func (s *service) GetFieldHistory(fieldID int) ([]model.FieldHolding, error) {
+	if err := s.checkField(fieldID); err != nil {
+		return nil, err
+	}
+	changes, err := s.repo.GetFieldHistory(fieldID)
+	if err != nil {
+		return nil, err
+	}
+	archiveTimes, err := s.repo.GetArchiveTimes()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	// conquerType -> start of the newer change, holdings end there
+	nextChange := make(map[string]model.FieldChange)
+	holdings := make([]model.FieldHolding, 0, len(changes))
+	for _, change := range changes {
+		next, hasNext := nextChange[change.ConquerType]
+		nextChange[change.ConquerType] = change
+		if change.Owner == "" {
+			continue
+		}
+
+		holding := model.FieldHolding{
+			Owner:       change.Owner,
+			ConquerType: change.ConquerType,
+			StartAt:     change.Time,
+		}
+		end := now
+		if hasNext {
+			end = next.Time
+		}
+		// the map is reset when a round is archived
+		i := sort.Search(len(archiveTimes), func(i int) bool {
+			return archiveTimes[i].After(change.Time)
+		})
+		if i < len(archiveTimes) && archiveTimes[i].Before(end) {
+			end = archiveTimes[i]
+		} else if !hasNext {
+			holding.Current = true
+		}
+		holding.HeldSeconds = end.Sub(change.Time).Seconds()
+		holdings = append(holdings, holding)
+	}
+	return holdings, nil
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zodius/api-war/broker"
+	"github.com/zodius/api-war/limiter"
+	"github.com/zodius/api-war/model"
+	"github.com/zodius/api-war/repo/memory"
+)
+
+func TestGetFieldHistory(t *testing.T) {
+	if _, ok := model.GetProtocol(testConquerType); !ok {
+		model.RegisterProtocol(model.Protocol{Name: testConquerType})
+	}
+
+	now := time.UnixMilli(time.Now().UnixMilli())
+	clock := func() time.Time { return now }
+	repo := memory.NewRepoWithClock(clock)
+	s := NewService(repo, broker.NewMemoryBroker(), limiter.NewMemoryLimiter(), testMap).(*service)
+	s.now = clock
+
+	for _, username := range []string{"alice", "bob"} {
+		if err := repo.CreateUser(username, "password"); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+	start := now
+	if _, err := repo.Conquer(7, testConquerType, "alice", model.FieldLock{}); err != nil {
+		t.Fatalf("Conquer: %v", err)
+	}
+	now = now.Add(10 * time.Second)
+	if _, err := repo.Conquer(7, testConquerType, "bob", model.FieldLock{}); err != nil {
+		t.Fatalf("Conquer: %v", err)
+	}
+	now = now.Add(15 * time.Second)
+
+	history, err := s.GetFieldHistory(7)
+	if err != nil {
+		t.Fatalf("GetFieldHistory: %v", err)
+	}
+	want := []model.FieldHolding{
+		{Owner: "bob", ConquerType: testConquerType, StartAt: start.Add(10 * time.Second), HeldSeconds: 15, Current: true},
+		{Owner: "alice", ConquerType: testConquerType, StartAt: start, HeldSeconds: 10},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("history = %+v, want %+v", history, want)
+	}
+	for i := range want {
+		if history[i] != want[i] {
+			t.Errorf("history[%d] = %+v, want %+v", i, history[i], want[i])
+		}
+	}
+
+	// archiving the round ends every holding
+	now = now.Add(5 * time.Second)
+	if err := repo.ArchiveRound(1); err != nil {
+		t.Fatalf("ArchiveRound: %v", err)
+	}
+	now = now.Add(time.Hour)
+	history, err = s.GetFieldHistory(7)
+	if err != nil {
+		t.Fatalf("GetFieldHistory: %v", err)
+	}
+	if history[0].Current || history[0].HeldSeconds != 20 {
+		t.Errorf("holding after archive = %+v, want 20 seconds, not current", history[0])
+	}
+
+	if _, err := s.GetFieldHistory(testMap.FieldCount + 1); !errors.Is(err, model.ErrFieldOutOfRange) {
+		t.Errorf("GetFieldHistory out of range: err = %v, want ErrFieldOutOfRange", err)
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2id parameters of newly hashed passwords, stored hashes with other
+// parameters still verify and are upgraded on the next login
+const (
+	argonVersion = argon2.Version
+	argonMemory  = 19 * 1024 // KiB
+	argonTime    = 2
+	argonThreads = 1
+	argonSaltLen = 16
+	argonKeyLen  = 32
+)
+
+const argonPrefix = "$argon2id$"
+
+var errInvalidHash = errors.New("invalid password hash")
+
+// dummyHash is verified against when the user doesn't exist
+var dummyHash, _ = hashPassword("")
+
+// hashPassword encodes password in the PHC string format:
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argonPrefix, argonVersion, argonMemory, argonTime, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// verifyPassword checks password against a stored hash in constant time,
+// needsRehash is set when the stored value is a legacy plaintext password
+// or was hashed with outdated parameters. Stored values that don't parse as
+// an argon2id hash are legacy plaintext passwords, even with the prefix.
+func verifyPassword(stored, password string) (ok bool, needsRehash bool, err error) {
+	hash, err := parseHash(stored)
+	if err != nil {
+		// legacy plaintext password
+		ok = subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return ok, true, nil
+	}
+
+	computed := argon2.IDKey([]byte(password), hash.salt, hash.time, hash.memory, hash.threads, uint32(len(hash.key)))
+	ok = subtle.ConstantTimeCompare(hash.key, computed) == 1
+	needsRehash = hash.memory != argonMemory || hash.time != argonTime || hash.threads != argonThreads || len(hash.key) != argonKeyLen
+	return ok, needsRehash, nil
+}
+
+type argonHash struct {
+	memory, time uint32
+	threads      uint8
+	salt, key    []byte
+}
+
+// parseHash reads a hash in the format of hashPassword, errInvalidHash for
+// anything else
+func parseHash(stored string) (argonHash, error) {
+	if !strings.HasPrefix(stored, argonPrefix) {
+		return argonHash{}, errInvalidHash
+	}
+	parts := strings.Split(stored, "$")
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	if len(parts) != 6 {
+		return argonHash{}, errInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argonVersion {
+		return argonHash{}, errInvalidHash
+	}
+
+	var hash argonHash
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &hash.memory, &hash.time, &hash.threads); err != nil {
+		return argonHash{}, errInvalidHash
+	}
+	// argon2 panics on zero time or threads
+	if hash.time < 1 || hash.threads < 1 {
+		return argonHash{}, errInvalidHash
+	}
+
+	var err error
+	if hash.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return argonHash{}, errInvalidHash
+	}
+	hash.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(hash.key) == 0 {
+		return argonHash{}, errInvalidHash
+	}
+	return hash, nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := hashPassword("hunter2")
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$") || strings.Contains(hash, "hunter2") {
+		t.Fatalf("unexpected hash format %q", hash)
+	}
+
+	other, err := hashPassword("hunter2")
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	if hash == other {
+		t.Error("hashes of the same password share a salt")
+	}
+
+	ok, needsRehash, err := verifyPassword(hash, "hunter2")
+	if err != nil || !ok || needsRehash {
+		t.Errorf("verify correct password = %v, %v, %v", ok, needsRehash, err)
+	}
+	ok, _, err = verifyPassword(hash, "hunter3")
+	if err != nil || ok {
+		t.Errorf("verify wrong password = %v, %v", ok, err)
+	}
+}
+
+func TestVerifyLegacyPassword(t *testing.T) {
+	ok, needsRehash, err := verifyPassword("hunter2", "hunter2")
+	if err != nil || !ok || !needsRehash {
+		t.Errorf("verify plaintext = %v, %v, %v, want match and rehash", ok, needsRehash, err)
+	}
+	ok, _, err = verifyPassword("hunter2", "hunter3")
+	if err != nil || ok {
+		t.Errorf("verify wrong plaintext = %v, %v", ok, err)
+	}
+}
+
+func TestVerifyOutdatedParameters(t *testing.T) {
+	salt := []byte("somesaltsomesalt")
+	key := argon2.IDKey([]byte("hunter2"), salt, 1, 1024, 1, 32)
+	outdated := "$argon2id$v=19$m=1024,t=1,p=1$" +
+		base64.RawStdEncoding.EncodeToString(salt) + "$" +
+		base64.RawStdEncoding.EncodeToString(key)
+
+	ok, needsRehash, err := verifyPassword(outdated, "hunter2")
+	if err != nil || !ok || !needsRehash {
+		t.Errorf("verify outdated hash = %v, %v, %v, want match and rehash", ok, needsRehash, err)
+	}
+}
+
+// values that only look like a hash are legacy plaintext passwords
+func TestVerifyMalformedHash(t *testing.T) {
+	hash, err := hashPassword("hunter2")
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	malformed := []string{
+		"$argon2id$v=19$m=1024,t=1,p=1$c29tZXNhbHQ$",
+		"$argon2id$v=19$m=1024,t=1,p=1",
+		strings.Replace(hash, "$v=19$", "$v=1$", 1),
+		"$argon2id$v=19$m=1024,t=0,p=1$c29tZXNhbHQ$c29tZWtleQ",
+	}
+	for _, stored := range malformed {
+		if ok, needsRehash, err := verifyPassword(stored, "hunter2"); ok || !needsRehash || err != nil {
+			t.Errorf("verifyPassword(%q, hunter2) = %v, %v, %v, want a plaintext mismatch", stored, ok, needsRehash, err)
+		}
+		if ok, _, err := verifyPassword(stored, stored); !ok || err != nil {
+			t.Errorf("verifyPassword(%q) as plaintext = %v, %v, want a match", stored, ok, err)
+		}
+	}
+}
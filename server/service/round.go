@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/zodius/api-war/model"
+)
+
+// roundCacheTTL bounds how long a backend keeps using a round after it was
+// changed on another backend, it is also the round watcher interval
+const roundCacheTTL = time.Second
+
+type roundCache struct {
+	lock       sync.Mutex
+	round      model.Round
+	configured bool
+	fetchedAt  time.Time
+	// frozen is the last round this backend saw frozen
+	frozen int
+}
+
+// currentRound returns the cached round, configured is false if no round is set
+func (s *service) currentRound() (round model.Round, configured bool, err error) {
+	s.rounds.lock.Lock()
+	defer s.rounds.lock.Unlock()
+
+	now := s.now()
+	if !s.rounds.fetchedAt.IsZero() && now.Sub(s.rounds.fetchedAt) < roundCacheTTL {
+		return s.rounds.round, s.rounds.configured, nil
+	}
+
+	round, err = s.repo.GetRound()
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		return model.Round{}, false, err
+	}
+	s.rounds.round = round
+	s.rounds.configured = err == nil
+	s.rounds.fetchedAt = now
+	return s.rounds.round, s.rounds.configured, nil
+}
+
+// checkRoundActive returns ErrRoundNotActive outside of the configured round
+func (s *service) checkRoundActive() error {
+	round, configured, err := s.currentRound()
+	if err != nil {
+		return err
+	}
+	if !configured {
+		return nil
+	}
+
+	switch round.State(s.now()) {
+	case model.RoundRunning:
+		return nil
+	case model.RoundFrozen:
+		// the scoreboard copy has to be taken before the first conquer
+		// after the freeze time
+		return s.freeze(round.ID)
+	default:
+		return model.ErrRoundNotActive
+	}
+}
+
+// freeze copies the scoreboard unless this backend already did for the round
+func (s *service) freeze(roundID int) error {
+	s.rounds.lock.Lock()
+	defer s.rounds.lock.Unlock()
+
+	if s.rounds.frozen == roundID {
+		return nil
+	}
+	if err := s.repo.FreezeScoreboard(roundID); err != nil {
+		return err
+	}
+	s.rounds.frozen = roundID
+	return nil
+}
+
+func (s *service) GetRound() (model.RoundStatus, error) {
+	round, configured, err := s.currentRound()
+	if err != nil {
+		return model.RoundStatus{}, err
+	}
+	if !configured {
+		return model.RoundStatus{}, model.ErrNotFound
+	}
+	return model.RoundStatus{
+		Round: round,
+		State: round.State(s.now()),
+	}, nil
+}
+
+func (s *service) SetRound(round model.Round) (model.Round, error) {
+	round, archive, err := s.nextRound(round)
+	if err != nil {
+		return model.Round{}, err
+	}
+	return round, s.applyRound(round, archive)
+}
+
+// nextRound validates round and gives it its id, archive is the id of an
+// ended round that has to be archived before round replaces it, 0 if none
+func (s *service) nextRound(round model.Round) (next model.Round, archive int, err error) {
+	if err := round.Validate(); err != nil {
+		return model.Round{}, 0, err
+	}
+	if round.Scoring == "" {
+		round.Scoring = model.ScoringConquers
+	}
+
+	current, err := s.repo.GetRound()
+	switch {
+	case errors.Is(err, model.ErrNotFound):
+		round.ID = 1
+	case err != nil:
+		return model.Round{}, 0, err
+	case current.State(s.now()) == model.RoundEnded:
+		round.ID = current.ID + 1
+		archive = current.ID
+	default:
+		round.ID = current.ID
+	}
+	return round, archive, nil
+}
+
+// applyRound stores a round from nextRound
+func (s *service) applyRound(round model.Round, archive int) error {
+	if archive != 0 {
+		// archive the ended round before its config is replaced, the
+		// watcher may not have done it yet
+		if err := s.repo.ArchiveRound(archive); err != nil {
+			return err
+		}
+	}
+	if err := s.repo.SetRound(round); err != nil {
+		return err
+	}
+
+	s.rounds.lock.Lock()
+	s.rounds.fetchedAt = time.Time{}
+	s.rounds.lock.Unlock()
+	return nil
+}
+
+func (s *service) GetRoundScoreboard(roundID, offset, limit int) ([]model.Score, error) {
+	limit, err := checkPage(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetArchivedScoreboard(roundID, offset, limit)
+}
+
+func (s *service) WatchRounds(ctx context.Context) {
+	ticker := time.NewTicker(roundCacheTTL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.advanceRound(); err != nil {
+				log.Printf("round watcher: %v", err)
+			}
+		}
+	}
+}
+
+// advanceRound freezes or archives the current round when it is due, both
+// are done once per round no matter how many backends call it
+func (s *service) advanceRound() error {
+	round, configured, err := s.currentRound()
+	if err != nil || !configured {
+		return err
+	}
+
+	switch round.State(s.now()) {
+	case model.RoundFrozen:
+		return s.freeze(round.ID)
+	case model.RoundEnded:
+		return s.repo.ArchiveRound(round.ID)
+	}
+	return nil
+}
+
+// frozenRound returns the id of the round whose scoreboard players see as it
+// was at the freeze time, 0 outside of a freeze window
+func (s *service) frozenRound() (int, error) {
+	round, configured, err := s.currentRound()
+	if err != nil || !configured || round.State(s.now()) != model.RoundFrozen {
+		return 0, err
+	}
+	if err := s.freeze(round.ID); err != nil {
+		return 0, err
+	}
+	return round.ID, nil
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zodius/api-war/broker"
+	"github.com/zodius/api-war/limiter"
+	"github.com/zodius/api-war/model"
+	"github.com/zodius/api-war/repo/memory"
+)
+
+const testConquerType = "test"
+
+var testMap = model.MapConfig{FieldCount: 100, BatchSize: 10}
+
+func TestRoundLifecycle(t *testing.T) {
+	if _, ok := model.GetProtocol(testConquerType); !ok {
+		model.RegisterProtocol(model.Protocol{Name: testConquerType})
+	}
+
+	now := time.Now()
+	repo := memory.NewRepo()
+	s := NewService(repo, broker.NewMemoryBroker(), limiter.NewMemoryLimiter(), testMap).(*service)
+	s.now = func() time.Time { return now }
+
+	if err := repo.CreateUser("alice", "password"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	token, err := repo.CreateToken("alice")
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	conquer := func(fieldID int) error {
+		t.Helper()
+		_, err := s.ConquerField(token, fieldID, testConquerType)
+		return err
+	}
+	held := func(scoreList []model.Score) int {
+		t.Helper()
+		if len(scoreList) != 1 {
+			t.Fatalf("scoreboard = %+v, want only alice", scoreList)
+		}
+		return scoreList[0].ConquerFieldCount
+	}
+
+	// without a round the game is always running
+	if err := conquer(1); err != nil {
+		t.Fatalf("ConquerField without round: %v", err)
+	}
+
+	round, err := s.SetRound(model.Round{
+		StartAt:  now.Add(time.Hour),
+		EndAt:    now.Add(3 * time.Hour),
+		FreezeAt: now.Add(2 * time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("SetRound: %v", err)
+	}
+	if round.ID != 1 {
+		t.Errorf("first round id = %d, want 1", round.ID)
+	}
+	if err := conquer(2); !errors.Is(err, model.ErrRoundNotActive) {
+		t.Errorf("ConquerField before start: err = %v, want ErrRoundNotActive", err)
+	}
+
+	now = now.Add(90 * time.Minute)
+	if err := conquer(2); err != nil {
+		t.Fatalf("ConquerField in round: %v", err)
+	}
+
+	// players see the scoreboard as it was at the freeze time
+	now = now.Add(time.Hour)
+	if status, _ := s.GetRound(); status.State != model.RoundFrozen {
+		t.Errorf("round state = %q, want frozen", status.State)
+	}
+	if err := conquer(3); err != nil {
+		t.Fatalf("ConquerField during freeze: %v", err)
+	}
+	scoreList, err := s.GetScoreboard(0, 0)
+	if err != nil {
+		t.Fatalf("GetScoreboard: %v", err)
+	}
+	if count := held(scoreList); count != 2 {
+		t.Errorf("frozen scoreboard count = %d, want 2", count)
+	}
+
+	now = now.Add(time.Hour)
+	if err := conquer(4); !errors.Is(err, model.ErrRoundNotActive) {
+		t.Errorf("ConquerField after end: err = %v, want ErrRoundNotActive", err)
+	}
+	if err := s.advanceRound(); err != nil {
+		t.Fatalf("advanceRound: %v", err)
+	}
+	final, err := s.GetRoundScoreboard(round.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("GetRoundScoreboard: %v", err)
+	}
+	if count := held(final); count != 3 {
+		t.Errorf("archived count = %d, want 3", count)
+	}
+
+	next, err := s.SetRound(model.Round{
+		StartAt: now,
+		EndAt:   now.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("SetRound: %v", err)
+	}
+	if next.ID != 2 {
+		t.Errorf("next round id = %d, want 2", next.ID)
+	}
+	scoreList, err = s.GetScoreboard(0, 0)
+	if err != nil {
+		t.Fatalf("GetScoreboard: %v", err)
+	}
+	if count := held(scoreList); count != 0 {
+		t.Errorf("next round starts with %d fields, want 0", count)
+	}
+}
+
+func TestSetRoundValidates(t *testing.T) {
+	s := NewService(memory.NewRepo(), broker.NewMemoryBroker(), limiter.NewMemoryLimiter(), testMap)
+	now := time.Now()
+
+	for _, round := range []model.Round{
+		{},
+		{StartAt: now, EndAt: now},
+		{StartAt: now, EndAt: now.Add(time.Hour), FreezeAt: now.Add(2 * time.Hour)},
+	} {
+		if _, err := s.SetRound(round); !errors.Is(err, model.ErrInvalidRound) {
+			t.Errorf("SetRound(%+v): err = %v, want ErrInvalidRound", round, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/zodius/api-war/broker"
+	"github.com/zodius/api-war/limiter"
+	"github.com/zodius/api-war/model"
+	"github.com/zodius/api-war/repo/memory"
+)
+
+func TestGetMyScore(t *testing.T) {
+	if _, ok := model.GetProtocol(testConquerType); !ok {
+		model.RegisterProtocol(model.Protocol{Name: testConquerType})
+	}
+
+	repo := memory.NewRepo()
+	s := NewService(repo, broker.NewMemoryBroker(), limiter.NewMemoryLimiter(), testMap)
+
+	// user<i> holds 5-i fields, so user0 ranks first
+	usernames := []string{"user0", "user1", "user2", "user3", "user4"}
+	fieldID := 1
+	for i, username := range usernames {
+		if err := repo.CreateUser(username, "password"); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		for held := 0; held < len(usernames)-i; held++ {
+			if _, err := repo.Conquer(fieldID, testConquerType, username, model.FieldLock{}); err != nil {
+				t.Fatalf("Conquer: %v", err)
+			}
+			fieldID++
+		}
+	}
+	token := func(username string) string {
+		t.Helper()
+		token, err := repo.CreateToken(username)
+		if err != nil {
+			t.Fatalf("CreateToken: %v", err)
+		}
+		return token
+	}
+	neighbours := func(me model.ScoreNeighbourhood) []string {
+		result := make([]string, 0, len(me.ScoreList))
+		for _, score := range me.ScoreList {
+			result = append(result, score.Username)
+		}
+		return result
+	}
+
+	me, err := s.GetMyScore(token("user2"), 1)
+	if err != nil {
+		t.Fatalf("GetMyScore: %v", err)
+	}
+	if me.Score.Username != "user2" || me.Score.Rank != 3 {
+		t.Errorf("score = %+v, want user2 at rank 3", me.Score)
+	}
+	if got := neighbours(me); len(got) != 3 || got[0] != "user1" || got[2] != "user3" {
+		t.Errorf("neighbours = %v, want user1 to user3", got)
+	}
+
+	// the page is cut at the top of the scoreboard
+	me, err = s.GetMyScore(token("user0"), 2)
+	if err != nil {
+		t.Fatalf("GetMyScore: %v", err)
+	}
+	if got := neighbours(me); len(got) != 3 || got[0] != "user0" {
+		t.Errorf("neighbours of the leader = %v, want user0 to user2", got)
+	}
+
+	if _, err := s.GetMyScore("invalid", 1); !errors.Is(err, model.ErrUnauthorized) {
+		t.Errorf("GetMyScore with invalid token: err = %v, want ErrUnauthorized", err)
+	}
+	if _, err := s.GetMyScore(token("user0"), model.MaxNeighbours+1); !errors.Is(err, model.ErrInvalidPage) {
+		t.Errorf("GetMyScore with too many neighbours: err = %v, want ErrInvalidPage", err)
+	}
+	if _, err := s.GetScoreboard(-1, 10); !errors.Is(err, model.ErrInvalidPage) {
+		t.Errorf("GetScoreboard with negative offset: err = %v, want ErrInvalidPage", err)
+	}
+	if _, err := s.GetScoreboard(0, model.MaxScoreboardLimit+1); !errors.Is(err, model.ErrInvalidPage) {
+		t.Errorf("GetScoreboard above the limit: err = %v, want ErrInvalidPage", err)
+	}
+}
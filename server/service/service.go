@@ -1,20 +1,44 @@
 package service
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/zodius/api-war/model"
 )
 
 type service struct {
-	repo model.Repo
+	repo             model.Repo
+	broker           model.Broker
+	limiter          model.RateLimiter
+	fieldCount       int
+	lock             model.FieldLock
+	conquerBatchSize int
+	now              func() time.Time
+	rounds           *roundCache
+	snapshots        *snapshotCache
 }
 
 func NewService(
 	repo model.Repo,
+	broker model.Broker,
+	limiter model.RateLimiter,
+	mapConfig model.MapConfig,
 ) model.Service {
 	return &service{
-		repo: repo,
+		repo:             repo,
+		broker:           broker,
+		limiter:          limiter,
+		fieldCount:       mapConfig.FieldCount,
+		lock:             mapConfig.Lock,
+		conquerBatchSize: mapConfig.ConquerBatchSize,
+		now:              time.Now,
+		rounds:           new(roundCache),
+		snapshots:        new(snapshotCache),
 	}
 }
 
@@ -23,8 +47,12 @@ func (s *service) Register(username, password string) error {
 	_, err := s.repo.GetUser(username)
 	if err != nil {
 		if errors.Is(err, model.ErrNotFound) {
-			// create new user
-			return s.repo.CreateUser(username, password)
+			// create new user, only the hash is stored
+			hash, err := hashPassword(password)
+			if err != nil {
+				return err
+			}
+			return s.repo.CreateUser(username, hash)
 		} else {
 			return err
 		}
@@ -37,15 +65,31 @@ func (s *service) Login(username, password string) (token string, err error) {
 	user, err := s.repo.GetUser(username)
 	if err != nil {
 		if errors.Is(err, model.ErrNotFound) {
+			// spend the same time as a wrong password, so usernames can't be probed
+			verifyPassword(dummyHash, password)
 			return "", model.ErrInvalidCredentials
 		} else {
 			return "", err
 		}
 	}
 
-	if user.Password != password {
+	ok, needsRehash, err := verifyPassword(user.Password, password)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
 		return "", model.ErrInvalidCredentials
 	}
+	if user.Banned {
+		return "", model.ErrBanned
+	}
+
+	if needsRehash {
+		// upgrade legacy plaintext or outdated hash, the login succeeds either way
+		if hash, err := hashPassword(password); err == nil {
+			_ = s.repo.SetPassword(username, hash)
+		}
+	}
 
 	return s.repo.CreateToken(username)
 }
@@ -54,13 +98,77 @@ func (s *service) GetMe(token string) (username string, err error) {
 	return s.repo.GetTokenUsername(token)
 }
 
+func (s *service) Logout(token string) error {
+	// verify token
+	if _, err := s.repo.GetTokenUsername(token); err != nil {
+		return err
+	}
+	return s.repo.DeleteToken(token)
+}
+
+func (s *service) LogoutAll(token string) error {
+	username, err := s.repo.GetTokenUsername(token)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeleteUserTokens(username)
+}
+
+func (s *service) GetSessions(token string) ([]model.Session, error) {
+	username, err := s.repo.GetTokenUsername(token)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.repo.GetUserTokens(username)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].ID = sessionID(sessions[i].Token)
+		sessions[i].Current = sessions[i].Token == token
+	}
+	return sessions, nil
+}
+
+func (s *service) GetFieldCount() int {
+	return s.fieldCount
+}
+
+// checkField returns ErrFieldOutOfRange for ids outside the configured map
+func (s *service) checkField(fieldID int) error {
+	if fieldID < 1 || fieldID > s.fieldCount {
+		return model.ErrFieldOutOfRange
+	}
+	return nil
+}
+
+// sessionID derives a stable identifier from a token which can't be used to authenticate
+func sessionID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
 func (s *service) GetCurrentMap(start, end int) (Map model.Map, err error) {
-	if start == 0 {
-		return s.repo.GetMap(1, model.FieldCount)
+	if start < 1 || start > end || end > s.fieldCount {
+		return model.Map{}, model.ErrInvalidRange
 	}
 	return s.repo.GetMap(start, end)
 }
 
+func (s *service) GetWholeMap() (Map model.Map, err error) {
+	return s.repo.GetMap(1, s.fieldCount)
+}
+
+func (s *service) GetFields(fieldIDs []int) (Map model.Map, err error) {
+	for _, fieldID := range fieldIDs {
+		if err := s.checkField(fieldID); err != nil {
+			return model.Map{}, err
+		}
+	}
+	return s.repo.GetFields(fieldIDs)
+}
+
 func (s *service) GetUserList(token string) (userList []model.User, err error) {
 	// verify token
 	_, err = s.repo.GetTokenUsername(token)
@@ -71,6 +179,10 @@ func (s *service) GetUserList(token string) (userList []model.User, err error) {
 }
 
 func (s *service) GetUserConquerField(token string, conquerType string) (fields []int, err error) {
+	if _, ok := model.GetProtocol(conquerType); !ok {
+		return nil, model.ErrUnknownProtocol
+	}
+
 	username, err := s.repo.GetTokenUsername(token)
 	if err != nil {
 		return nil, err
@@ -79,21 +191,218 @@ func (s *service) GetUserConquerField(token string, conquerType string) (fields
 	return s.repo.GetUserConquerField(username, conquerType)
 }
 
-func (s *service) ConquerField(token string, fieldID int, conquerType string) error {
+func (s *service) ConquerField(token string, fieldID int, conquerType string) (model.ConquerResult, error) {
+	protocol, ok := model.GetProtocol(conquerType)
+	if !ok {
+		return model.ConquerResult{}, model.ErrUnknownProtocol
+	}
+	if err := s.checkField(fieldID); err != nil {
+		return model.ConquerResult{}, err
+	}
+
+	if err := s.checkRoundActive(); err != nil {
+		return model.ConquerResult{}, err
+	}
+
 	username, err := s.repo.GetTokenUsername(token)
 	if err != nil {
-		return err
+		return model.ConquerResult{}, err
+	}
+
+	if err := s.allow(fmt.Sprintf("%s:user:%s", conquerType, username), protocol.RateLimit, 1); err != nil {
+		return model.ConquerResult{}, err
 	}
 
-	err = s.repo.SetFieldConquerer(fieldID, conquerType, username)
+	// set owner and add score in one atomic step
+	previousOwner, err := s.repo.Conquer(fieldID, conquerType, username, s.lock)
+	if err != nil {
+		return model.ConquerResult{}, err
+	}
+
+	result := model.ConquerResult{
+		FieldID:         fieldID,
+		PreviousOwner:   previousOwner,
+		NewOwner:        username,
+		WasAlreadyOwned: previousOwner == username,
+		Timestamp:       s.now(),
+	}
+	if result.WasAlreadyOwned {
+		return result, nil
+	}
+	result.PointsAwarded = 1
+
+	// push update to subscribers, this is best effort since the field is already conquered
+	_ = s.broker.Publish(model.ConquerEvent{
+		FieldID:     fieldID,
+		ConquerType: conquerType,
+		Owner:       username,
+	})
+	return result, nil
+}
+
+func (s *service) CheckConquerBatch(fieldIDs []int) error {
+	if len(fieldIDs) == 0 || len(fieldIDs) > s.conquerBatchSize {
+		return fmt.Errorf("%w: batch must have 1 to %d fields", model.ErrInvalidBatch, s.conquerBatchSize)
+	}
+	return nil
+}
+
+func (s *service) ConquerFields(token string, fieldIDs []int, conquerType string) ([]model.ConquerItem, error) {
+	protocol, ok := model.GetProtocol(conquerType)
+	if !ok {
+		return nil, model.ErrUnknownProtocol
+	}
+	if err := s.CheckConquerBatch(fieldIDs); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkRoundActive(); err != nil {
+		return nil, err
+	}
+
+	username, err := s.repo.GetTokenUsername(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// every field costs a request, including those out of range
+	if err := s.allow(fmt.Sprintf("%s:user:%s", conquerType, username), protocol.RateLimit, len(fieldIDs)); err != nil {
+		return nil, err
+	}
+
+	items := make([]model.ConquerItem, len(fieldIDs))
+	valid := make([]int, 0, len(fieldIDs))
+	for i, fieldID := range fieldIDs {
+		items[i].FieldID = fieldID
+		if err := s.checkField(fieldID); err != nil {
+			items[i].Err = err
+			continue
+		}
+		valid = append(valid, fieldID)
+	}
+	if len(valid) == 0 {
+		return items, nil
+	}
+
+	outcomes, err := s.repo.ConquerFields(valid, conquerType, username, s.lock)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	for i := range items {
+		if items[i].Err != nil {
+			continue
+		}
+		outcome := outcomes[0]
+		outcomes = outcomes[1:]
+		if outcome.Err != nil {
+			items[i].Err = outcome.Err
+			continue
+		}
+
+		items[i].ConquerResult = model.ConquerResult{
+			FieldID:         items[i].FieldID,
+			PreviousOwner:   outcome.PreviousOwner,
+			NewOwner:        username,
+			WasAlreadyOwned: outcome.PreviousOwner == username,
+			Timestamp:       now,
+		}
+		if items[i].WasAlreadyOwned {
+			continue
+		}
+		items[i].PointsAwarded = 1
+		// best effort like ConquerField
+		_ = s.broker.Publish(model.ConquerEvent{
+			FieldID:     items[i].FieldID,
+			ConquerType: conquerType,
+			Owner:       username,
+		})
+	}
+	return items, nil
+}
+
+func (s *service) CheckClientRateLimit(ip string, conquerType string, cost int) error {
+	protocol, ok := model.GetProtocol(conquerType)
+	if !ok {
+		return model.ErrUnknownProtocol
+	}
+	return s.allow(fmt.Sprintf("%s:ip:%s", conquerType, ip), protocol.IPRateLimit, cost)
+}
+
+// allow takes cost requests from a rate limit bucket, returns a *model.RateLimitError if denied
+func (s *service) allow(key string, limit model.RateLimit, cost int) error {
+	allowed, retryAfter, err := s.limiter.Allow(key, limit, cost)
 	if err != nil {
 		return err
 	}
+	if !allowed {
+		return &model.RateLimitError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+func (s *service) SubscribeFieldUpdates(ctx context.Context) (<-chan model.ConquerEvent, error) {
+	return s.broker.Subscribe(ctx)
+}
+
+func (s *service) GetScoreboard(offset, limit int) (scoreList []model.Score, err error) {
+	if limit, err = checkPage(offset, limit); err != nil {
+		return nil, err
+	}
+	frozen, err := s.frozenRound()
+	if err != nil {
+		return nil, err
+	}
+	if frozen != 0 {
+		return s.repo.GetFrozenScoreboard(frozen, offset, limit)
+	}
+	return s.repo.GetScoreboard(offset, limit)
+}
+
+func (s *service) GetMyScore(token string, around int) (model.ScoreNeighbourhood, error) {
+	if around < 0 || around > model.MaxNeighbours {
+		return model.ScoreNeighbourhood{}, fmt.Errorf("%w: around must be between 0 and %d", model.ErrInvalidPage, model.MaxNeighbours)
+	}
+	username, err := s.repo.GetTokenUsername(token)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return model.ScoreNeighbourhood{}, model.ErrUnauthorized
+		}
+		return model.ScoreNeighbourhood{}, err
+	}
+	frozen, err := s.frozenRound()
+	if err != nil {
+		return model.ScoreNeighbourhood{}, err
+	}
 
-	// add score
-	return s.repo.AddScore(username, fieldID, conquerType)
+	var scoreList []model.Score
+	if frozen != 0 {
+		scoreList, err = s.repo.GetFrozenScoreNeighbourhood(frozen, username, around)
+	} else {
+		scoreList, err = s.repo.GetScoreNeighbourhood(username, around)
+	}
+	if err != nil {
+		return model.ScoreNeighbourhood{}, err
+	}
+	for _, score := range scoreList {
+		if score.Username == username {
+			return model.ScoreNeighbourhood{
+				Score:     score,
+				ScoreList: scoreList,
+			}, nil
+		}
+	}
+	// the user moved between the rank and the page read of a count ranking
+	return model.ScoreNeighbourhood{}, fmt.Errorf("rank of %s changed, try again", username)
 }
 
-func (s *service) GetScoreboard() (scoreList []model.Score, err error) {
-	return s.repo.GetScoreboard()
+// checkPage validates a scoreboard page and applies the default limit
+func checkPage(offset, limit int) (int, error) {
+	if limit == 0 {
+		limit = model.DefaultScoreboardLimit
+	}
+	if offset < 0 || limit < 0 || limit > model.MaxScoreboardLimit {
+		return 0, fmt.Errorf("%w: offset must not be negative and limit must be between 1 and %d", model.ErrInvalidPage, model.MaxScoreboardLimit)
+	}
+	return limit, nil
 }
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zodius/api-war/broker"
+	"github.com/zodius/api-war/limiter"
+	"github.com/zodius/api-war/model"
+	"github.com/zodius/api-war/repo/memory"
+)
+
+func TestRegisterStoresHash(t *testing.T) {
+	repo := memory.NewRepo()
+	service := NewService(repo, broker.NewMemoryBroker(), limiter.NewMemoryLimiter(), testMap)
+
+	if err := service.Register("alice", "hunter2"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	user, err := repo.GetUser("alice")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if !strings.HasPrefix(user.Password, argonPrefix) {
+		t.Errorf("stored password %q is not hashed", user.Password)
+	}
+
+	if _, err := service.Login("alice", "hunter2"); err != nil {
+		t.Errorf("Login: %v", err)
+	}
+	if _, err := service.Login("alice", "hunter3"); !errors.Is(err, model.ErrInvalidCredentials) {
+		t.Errorf("Login with wrong password error = %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := service.Login("bob", "hunter2"); !errors.Is(err, model.ErrInvalidCredentials) {
+		t.Errorf("Login unknown user error = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestLoginRehashesLegacyPassword(t *testing.T) {
+	repo := memory.NewRepo()
+	service := NewService(repo, broker.NewMemoryBroker(), limiter.NewMemoryLimiter(), testMap)
+
+	// users registered before hashing have their password in plaintext
+	if err := repo.CreateUser("alice", "hunter2"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	if _, err := service.Login("alice", "wrong"); !errors.Is(err, model.ErrInvalidCredentials) {
+		t.Fatalf("Login with wrong password error = %v", err)
+	}
+	if user, _ := repo.GetUser("alice"); user.Password != "hunter2" {
+		t.Fatalf("failed login rehashed password to %q", user.Password)
+	}
+
+	if _, err := service.Login("alice", "hunter2"); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	user, _ := repo.GetUser("alice")
+	if !strings.HasPrefix(user.Password, argonPrefix) {
+		t.Fatalf("legacy password not rehashed: %q", user.Password)
+	}
+	if _, err := service.Login("alice", "hunter2"); err != nil {
+		t.Errorf("Login after rehash: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/zodius/api-war/model"
+)
+
+// snapshotCache keeps the last encoded snapshot, it is rebuilt only after the
+// map version changed so polling clients share one encoding
+type snapshotCache struct {
+	lock    sync.Mutex
+	version uint64
+	data    []byte
+}
+
+func (s *service) GetMapSnapshot() ([]byte, uint64, error) {
+	version, err := s.repo.GetMapVersion()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	s.snapshots.lock.Lock()
+	defer s.snapshots.lock.Unlock()
+
+	if s.snapshots.data != nil && s.snapshots.version >= version {
+		return s.snapshots.data, s.snapshots.version, nil
+	}
+
+	snapshot, err := s.repo.GetMapSnapshot(s.fieldCount)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := snapshot.MarshalBinary()
+	if err != nil {
+		return nil, 0, err
+	}
+	s.snapshots.version = snapshot.Version
+	s.snapshots.data = data
+	return data, snapshot.Version, nil
+}
+
+func (s *service) GetMapChanges(since uint64) (model.MapChanges, error) {
+	return s.repo.GetMapChanges(since)
+}
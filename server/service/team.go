@@ -0,0 +1,143 @@
+package service
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/zodius/api-war/model"
+)
+
+// player resolves the token to a username, ErrUnauthorized for unknown tokens
+func (s *service) player(token string) (string, error) {
+	username, err := s.repo.GetTokenUsername(token)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return "", model.ErrUnauthorized
+		}
+		return "", err
+	}
+	return username, nil
+}
+
+func (s *service) CreateTeam(token, name string) (model.Team, error) {
+	if err := model.ValidateTeamName(name); err != nil {
+		return model.Team{}, err
+	}
+	username, err := s.player(token)
+	if err != nil {
+		return model.Team{}, err
+	}
+	return s.repo.CreateTeam(name, username)
+}
+
+func (s *service) InviteToTeam(token string, teamID int, username string) error {
+	inviter, err := s.player(token)
+	if err != nil {
+		return err
+	}
+	team, err := s.repo.GetTeam(teamID)
+	if err != nil {
+		return err
+	}
+	if !team.HasMember(inviter) {
+		return model.ErrNotTeamMember
+	}
+
+	user, err := s.repo.GetUser(username)
+	if err != nil {
+		return err
+	}
+	if user.TeamID != 0 {
+		return model.ErrAlreadyInTeam
+	}
+	if len(team.Members) >= model.MaxTeamSize {
+		return model.ErrTeamFull
+	}
+	return s.repo.InviteToTeam(teamID, username)
+}
+
+func (s *service) JoinTeam(token string, teamID int) (model.Team, error) {
+	username, err := s.player(token)
+	if err != nil {
+		return model.Team{}, err
+	}
+	// tell unknown teams apart from missing invites
+	if _, err := s.repo.GetTeam(teamID); err != nil {
+		return model.Team{}, err
+	}
+	if err := s.repo.JoinTeam(teamID, username); err != nil {
+		return model.Team{}, err
+	}
+	return s.repo.GetTeam(teamID)
+}
+
+func (s *service) GetTeam(teamID int) (model.Team, error) {
+	return s.repo.GetTeam(teamID)
+}
+
+func (s *service) GetTeams() ([]model.Team, error) {
+	return s.repo.GetTeams()
+}
+
+func (s *service) GetTeamScoreboard(offset, limit int) ([]model.TeamScore, error) {
+	limit, err := checkPage(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	frozen, err := s.frozenRound()
+	if err != nil {
+		return nil, err
+	}
+
+	var scores []model.TeamScore
+	if frozen != 0 {
+		scores, err = s.repo.GetFrozenTeamScores(frozen)
+	} else {
+		scores, err = s.repo.GetTeamScores()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	round, configured, err := s.currentRound()
+	if err != nil {
+		return nil, err
+	}
+	scoring := model.ScoringConquers
+	if configured && round.Scoring != "" {
+		scoring = round.Scoring
+	}
+	rankTeams(scores, scoring)
+	if offset >= len(scores) {
+		return make([]model.TeamScore, 0), nil
+	}
+	return scores[offset:min(offset+limit, len(scores))], nil
+}
+
+// rankTeams orders teams by held fields, or by hold points first under hold
+// time scoring, then by conquers, ties go to the older team
+func rankTeams(scores []model.TeamScore, scoring model.Scoring) {
+	conquers := func(score model.TeamScore) int {
+		total := 0
+		for _, count := range score.ConquerHistoryCount {
+			total += count
+		}
+		return total
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		a, b := scores[i], scores[j]
+		if scoring == model.ScoringHoldTime && a.HoldPoints != b.HoldPoints {
+			return a.HoldPoints > b.HoldPoints
+		}
+		if a.ConquerFieldCount != b.ConquerFieldCount {
+			return a.ConquerFieldCount > b.ConquerFieldCount
+		}
+		if conquers(a) != conquers(b) {
+			return conquers(a) > conquers(b)
+		}
+		return a.TeamID < b.TeamID
+	})
+	for i := range scores {
+		scores[i].Rank = i + 1
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zodius/api-war/broker"
+	"github.com/zodius/api-war/limiter"
+	"github.com/zodius/api-war/model"
+	"github.com/zodius/api-war/repo/memory"
+)
+
+func TestTeams(t *testing.T) {
+	if _, ok := model.GetProtocol(testConquerType); !ok {
+		model.RegisterProtocol(model.Protocol{Name: testConquerType})
+	}
+
+	repo := memory.NewRepo()
+	s := NewService(repo, broker.NewMemoryBroker(), limiter.NewMemoryLimiter(), testMap)
+
+	tokens := make(map[string]string)
+	for _, username := range []string{"alice", "bob", "carol", "dave"} {
+		if err := repo.CreateUser(username, "password"); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		token, err := repo.CreateToken(username)
+		if err != nil {
+			t.Fatalf("CreateToken: %v", err)
+		}
+		tokens[username] = token
+	}
+
+	if _, err := s.CreateTeam(tokens["alice"], " red"); !errors.Is(err, model.ErrInvalidTeam) {
+		t.Errorf("CreateTeam with padded name: got %v, want ErrInvalidTeam", err)
+	}
+	if _, err := s.CreateTeam("invalid", "red"); !errors.Is(err, model.ErrUnauthorized) {
+		t.Errorf("CreateTeam with unknown token: got %v, want ErrUnauthorized", err)
+	}
+	red, err := s.CreateTeam(tokens["alice"], "red")
+	if err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+	blue, err := s.CreateTeam(tokens["carol"], "blue")
+	if err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+
+	if err := s.InviteToTeam(tokens["bob"], red.ID, "bob"); !errors.Is(err, model.ErrNotTeamMember) {
+		t.Errorf("InviteToTeam by outsider: got %v, want ErrNotTeamMember", err)
+	}
+	if err := s.InviteToTeam(tokens["alice"], red.ID, "carol"); !errors.Is(err, model.ErrAlreadyInTeam) {
+		t.Errorf("InviteToTeam of a member of another team: got %v, want ErrAlreadyInTeam", err)
+	}
+	if _, err := s.JoinTeam(tokens["bob"], red.ID+10); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("JoinTeam of unknown team: got %v, want ErrNotFound", err)
+	}
+	if err := s.InviteToTeam(tokens["alice"], red.ID, "bob"); err != nil {
+		t.Fatalf("InviteToTeam: %v", err)
+	}
+	joined, err := s.JoinTeam(tokens["bob"], red.ID)
+	if err != nil {
+		t.Fatalf("JoinTeam: %v", err)
+	}
+	if len(joined.Members) != 2 || len(joined.Invites) != 0 {
+		t.Errorf("team after join = %+v, want 2 members and no invites", joined)
+	}
+
+	// blue holds two fields, red one, dave has no team
+	for fieldID, username := range map[int]string{1: "alice", 2: "carol", 3: "carol", 4: "dave"} {
+		if _, err := repo.Conquer(fieldID, testConquerType, username, model.FieldLock{}); err != nil {
+			t.Fatalf("Conquer: %v", err)
+		}
+	}
+	if _, err := s.ConquerField(tokens["bob"], 1, testConquerType); !errors.Is(err, model.ErrTeammateField) {
+		t.Errorf("ConquerField of a teammate's field: got %v, want ErrTeammateField", err)
+	}
+
+	scores, err := s.GetTeamScoreboard(0, 0)
+	if err != nil {
+		t.Fatalf("GetTeamScoreboard: %v", err)
+	}
+	if len(scores) != 2 || scores[0].TeamID != blue.ID || scores[0].Rank != 1 || scores[1].TeamID != red.ID || scores[1].Rank != 2 {
+		t.Errorf("team scoreboard = %+v, want blue then red", scores)
+	}
+	if page, err := s.GetTeamScoreboard(1, 1); err != nil || len(page) != 1 || page[0].TeamID != red.ID {
+		t.Errorf("second page = %+v, %v, want red", page, err)
+	}
+	if page, err := s.GetTeamScoreboard(5, 1); err != nil || len(page) != 0 {
+		t.Errorf("page after the last team = %+v, %v, want empty", page, err)
+	}
+}
+
+func TestTeamNameLength(t *testing.T) {
+	if _, ok := model.GetProtocol(testConquerType); !ok {
+		model.RegisterProtocol(model.Protocol{Name: testConquerType})
+	}
+
+	repo := memory.NewRepo()
+	s := NewService(repo, broker.NewMemoryBroker(), limiter.NewMemoryLimiter(), testMap)
+	if err := repo.CreateUser("alice", "password"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	token, err := repo.CreateToken("alice")
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+
+	// the limit counts characters, these take two to four bytes each
+	for name, want := range map[string]error{
+		strings.Repeat("ü", model.MaxTeamNameLength+1): model.ErrInvalidTeam,
+		"red\xff": model.ErrInvalidTeam,
+		strings.Repeat("紅", model.MaxTeamNameLength): nil,
+	} {
+		if _, err := s.CreateTeam(token, name); !errors.Is(err, want) {
+			t.Errorf("CreateTeam(%q): got %v, want %v", name, err, want)
+		}
+	}
+}
+
+func TestRankTeamsHoldTime(t *testing.T) {
+	scores := []model.TeamScore{
+		{TeamID: 1, ConquerFieldCount: 5, HoldPoints: 10},
+		{TeamID: 2, ConquerFieldCount: 1, HoldPoints: 30},
+	}
+	rankTeams(scores, model.ScoringConquers)
+	if scores[0].TeamID != 1 {
+		t.Errorf("conquer scoring = %+v, want team 1 first", scores)
+	}
+	rankTeams(scores, model.ScoringHoldTime)
+	if scores[0].TeamID != 2 || scores[0].Rank != 1 {
+		t.Errorf("hold time scoring = %+v, want team 2 first", scores)
+	}
+}
@@ -0,0 +1,36 @@
+package graph
+
+import (
+	"context"
+
+	apimodel "github.com/zodius/api-war/model"
+	"github.com/zodius/api-war/tools/graph/model"
+)
+
+// contextToken returns the request token, admin calls reject an empty one
+func contextToken(ctx context.Context) string {
+	token, _ := ctx.Value("token").(string)
+	return token
+}
+
+// optionalInt returns the value of an optional argument or fallback
+func optionalInt(value *int, fallback int) int {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
+
+func convertRound(round apimodel.RoundStatus) *model.Round {
+	result := &model.Round{
+		ID:      round.ID,
+		StartAt: round.StartAt,
+		EndAt:   round.EndAt,
+		State:   string(round.State),
+		Scoring: string(round.Scoring),
+	}
+	if !round.FreezeAt.IsZero() {
+		result.FreezeAt = &round.FreezeAt
+	}
+	return result
+}
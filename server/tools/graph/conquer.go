@@ -0,0 +1,45 @@
+package graph
+
+import (
+	"errors"
+
+	apimodel "github.com/zodius/api-war/model"
+	"github.com/zodius/api-war/tools/graph/model"
+)
+
+func convertConquerResult(result apimodel.ConquerResult) *model.ConquerResult {
+	return &model.ConquerResult{
+		FieldID:         result.FieldID,
+		PreviousOwner:   result.PreviousOwner,
+		NewOwner:        result.NewOwner,
+		PointsAwarded:   result.PointsAwarded,
+		WasAlreadyOwned: result.WasAlreadyOwned,
+		Timestamp:       result.Timestamp,
+	}
+}
+
+// convertConquerItem reports field errors with the codes the error presenter
+// gives conquerField errors
+func convertConquerItem(item apimodel.ConquerItem) *model.ConquerFieldsItem {
+	result := &model.ConquerFieldsItem{FieldID: item.FieldID}
+	if item.Err == nil {
+		result.Result = convertConquerResult(item.ConquerResult)
+		return result
+	}
+
+	message := item.Err.Error()
+	result.Error = &message
+	var locked *apimodel.FieldLockedError
+	var code string
+	switch {
+	case errors.As(item.Err, &locked):
+		code = "FIELD_LOCKED"
+		result.UnlockAt = &locked.UnlockAt
+	case errors.Is(item.Err, apimodel.ErrTeammateField):
+		code = "TEAMMATE_FIELD"
+	}
+	if code != "" {
+		result.Code = &code
+	}
+	return result
+}
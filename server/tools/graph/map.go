@@ -0,0 +1,32 @@
+package graph
+
+import (
+	apimodel "github.com/zodius/api-war/model"
+	"github.com/zodius/api-war/tools/graph/model"
+)
+
+// maxMapRange is the most fields one map query returns, like GET /map
+const maxMapRange = 1000
+
+// checkFieldID rejects ids outside the configured map before they reach the
+// field resolvers
+func (r *Resolver) checkFieldID(id int) error {
+	if id < 1 || id > r.Service.GetFieldCount() {
+		return apimodel.ErrFieldOutOfRange
+	}
+	return nil
+}
+
+func convertField(field apimodel.Field) *model.Field {
+	owners := make([]*model.FieldOwner, 0, len(field.Conquerer))
+	for _, owner := range field.Conquerer {
+		owners = append(owners, &model.FieldOwner{
+			ConquerType: owner.ConquerType,
+			Owner:       owner.Owner,
+		})
+	}
+	return &model.Field{
+		ID:     field.FieldID,
+		Owners: owners,
+	}
+}
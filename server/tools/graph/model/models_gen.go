@@ -2,8 +2,75 @@
 
 package model
 
+import (
+	"time"
+)
+
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Admin  string    `json:"admin"`
+	Action string    `json:"action"`
+	Target string    `json:"target"`
+	Detail string    `json:"detail"`
+}
+
+type ConquerFieldsItem struct {
+	FieldID  int            `json:"fieldID"`
+	Result   *ConquerResult `json:"result,omitempty"`
+	Error    *string        `json:"error,omitempty"`
+	Code     *string        `json:"code,omitempty"`
+	UnlockAt *time.Time     `json:"unlockAt,omitempty"`
+}
+
+type ConquerHistory struct {
+	ConquerType string `json:"conquerType"`
+	Count       int    `json:"count"`
+}
+
+type ConquerResult struct {
+	FieldID         int       `json:"fieldID"`
+	PreviousOwner   string    `json:"previousOwner"`
+	NewOwner        string    `json:"newOwner"`
+	PointsAwarded   int       `json:"pointsAwarded"`
+	WasAlreadyOwned bool      `json:"wasAlreadyOwned"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
 type Field struct {
-	ID int `json:"ID"`
+	ID      int             `json:"ID"`
+	Owners  []*FieldOwner   `json:"owners"`
+	History []*FieldHolding `json:"history"`
+}
+
+type FieldHolding struct {
+	Owner       string    `json:"owner"`
+	ConquerType string    `json:"conquerType"`
+	StartAt     time.Time `json:"startAt"`
+	HeldSeconds float64   `json:"heldSeconds"`
+	Current     bool      `json:"current"`
+}
+
+type FieldOwner struct {
+	ConquerType string `json:"conquerType"`
+	Owner       string `json:"owner"`
+}
+
+type FieldUpdate struct {
+	FieldID     int    `json:"fieldID"`
+	ConquerType string `json:"conquerType"`
+	Owner       string `json:"owner"`
+}
+
+type MapChange struct {
+	Version     int    `json:"version"`
+	FieldID     int    `json:"fieldID"`
+	ConquerType string `json:"conquerType"`
+	Owner       string `json:"owner"`
+}
+
+type MapChanges struct {
+	Version int          `json:"version"`
+	Changes []*MapChange `json:"changes"`
 }
 
 type Mutation struct {
@@ -11,3 +78,49 @@ type Mutation struct {
 
 type Query struct {
 }
+
+type Round struct {
+	ID       int        `json:"id"`
+	StartAt  time.Time  `json:"startAt"`
+	EndAt    time.Time  `json:"endAt"`
+	FreezeAt *time.Time `json:"freezeAt,omitempty"`
+	State    string     `json:"state"`
+	Scoring  string     `json:"scoring"`
+}
+
+type Score struct {
+	Rank                int               `json:"rank"`
+	Username            string            `json:"username"`
+	ConquerFieldCount   int               `json:"conquerFieldCount"`
+	ConquerHistoryCount []*ConquerHistory `json:"conquerHistoryCount"`
+	HoldPoints          int               `json:"holdPoints"`
+}
+
+type ScoreNeighbourhood struct {
+	Score     *Score   `json:"score"`
+	ScoreList []*Score `json:"scoreList"`
+}
+
+type Session struct {
+	ID         string    `json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	Current    bool      `json:"current"`
+}
+
+type Subscription struct {
+}
+
+type User struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+type UserStats struct {
+	ID                  int               `json:"id"`
+	Username            string            `json:"username"`
+	Role                string            `json:"role"`
+	Banned              bool              `json:"banned"`
+	ConquerFieldCount   int               `json:"conquerFieldCount"`
+	ConquerHistoryCount []*ConquerHistory `json:"conquerHistoryCount"`
+}
@@ -8,4 +8,8 @@ import "github.com/zodius/api-war/model"
 
 type Resolver struct {
 	Service model.Service
+	// ConquerType is the protocol name fields are conquered as
+	ConquerType string
+
+	scoreboards scoreboardHub
 }
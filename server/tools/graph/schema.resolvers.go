@@ -7,10 +7,52 @@ package graph
 import (
 	"context"
 	"errors"
+	"slices"
+	"time"
 
+	"github.com/99designs/gqlgen/graphql"
+	apimodel "github.com/zodius/api-war/model"
 	"github.com/zodius/api-war/tools/graph/model"
 )
 
+// Owners is the resolver for the owners field.
+func (r *fieldResolver) Owners(ctx context.Context, obj *model.Field) ([]*model.FieldOwner, error) {
+	if err := r.Resolver.checkFieldID(obj.ID); err != nil {
+		return nil, err
+	}
+	// fields from map and fields already carry their owners
+	if obj.Owners != nil {
+		return obj.Owners, nil
+	}
+	fieldMap, err := r.Resolver.Service.GetFields([]int{obj.ID})
+	if err != nil {
+		return nil, err
+	}
+	if len(fieldMap.Fields) == 0 {
+		return make([]*model.FieldOwner, 0), nil
+	}
+	return convertField(fieldMap.Fields[0]).Owners, nil
+}
+
+// History is the resolver for the history field.
+func (r *fieldResolver) History(ctx context.Context, obj *model.Field) ([]*model.FieldHolding, error) {
+	history, err := r.Resolver.Service.GetFieldHistory(obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*model.FieldHolding, 0, len(history))
+	for _, holding := range history {
+		result = append(result, &model.FieldHolding{
+			Owner:       holding.Owner,
+			ConquerType: holding.ConquerType,
+			StartAt:     holding.StartAt,
+			HeldSeconds: holding.HeldSeconds,
+			Current:     holding.Current,
+		})
+	}
+	return result, nil
+}
+
 // Login is the resolver for the login field.
 func (r *mutationResolver) Login(ctx context.Context, username string, password string) (*string, error) {
 	token, err := r.Resolver.Service.Login(username, password)
@@ -23,16 +65,170 @@ func (r *mutationResolver) Register(ctx context.Context, username string, passwo
 	return nil, err
 }
 
+// Logout is the resolver for the logout field.
+func (r *mutationResolver) Logout(ctx context.Context) (bool, error) {
+	token := ctx.Value("token")
+	if token == nil {
+		return false, errors.New("token is required")
+	}
+	tokenStr := token.(string)
+	if err := r.Resolver.Service.Logout(tokenStr); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// LogoutAll is the resolver for the logoutAll field.
+func (r *mutationResolver) LogoutAll(ctx context.Context) (bool, error) {
+	token := ctx.Value("token")
+	if token == nil {
+		return false, errors.New("token is required")
+	}
+	tokenStr := token.(string)
+	if err := r.Resolver.Service.LogoutAll(tokenStr); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // ConquerField is the resolver for the conquerField field.
-func (r *mutationResolver) ConquerField(ctx context.Context, fieldID int) (*int, error) {
+func (r *mutationResolver) ConquerField(ctx context.Context, fieldID int) (*model.ConquerResult, error) {
 	// get token from context
 	token := ctx.Value("token")
 	if token == "" {
 		return nil, errors.New("token is required")
 	}
 	tokenStr := token.(string)
-	err := r.Resolver.Service.ConquerField(tokenStr, fieldID, "graphql")
-	return nil, err
+	// aliased mutations in one request are limited one by one
+	ip, _ := ctx.Value("ip").(string)
+	if err := r.Resolver.Service.CheckClientRateLimit(ip, r.Resolver.ConquerType, 1); err != nil {
+		return nil, err
+	}
+	result, err := r.Resolver.Service.ConquerField(tokenStr, fieldID, r.Resolver.ConquerType)
+	if err != nil {
+		return nil, err
+	}
+	return convertConquerResult(result), nil
+}
+
+// ConquerFields is the resolver for the conquerFields field.
+func (r *mutationResolver) ConquerFields(ctx context.Context, ids []int) ([]*model.ConquerFieldsItem, error) {
+	// an invalid batch must not use up the limit
+	if err := r.Resolver.Service.CheckConquerBatch(ids); err != nil {
+		return nil, err
+	}
+	ip, _ := ctx.Value("ip").(string)
+	if err := r.Resolver.Service.CheckClientRateLimit(ip, r.Resolver.ConquerType, len(ids)); err != nil {
+		return nil, err
+	}
+	items, err := r.Resolver.Service.ConquerFields(contextToken(ctx), ids, r.Resolver.ConquerType)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*model.ConquerFieldsItem, 0, len(items))
+	for _, item := range items {
+		result = append(result, convertConquerItem(item))
+	}
+	return result, nil
+}
+
+// BanUser is the resolver for the banUser field.
+func (r *mutationResolver) BanUser(ctx context.Context, username string) (bool, error) {
+	if err := r.Resolver.Service.AdminBanUser(contextToken(ctx), username); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UnbanUser is the resolver for the unbanUser field.
+func (r *mutationResolver) UnbanUser(ctx context.Context, username string) (bool, error) {
+	if err := r.Resolver.Service.AdminUnbanUser(contextToken(ctx), username); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetFieldOwner is the resolver for the setFieldOwner field.
+func (r *mutationResolver) SetFieldOwner(ctx context.Context, conquerType string, fieldID int, owner *string) (bool, error) {
+	ownerName := ""
+	if owner != nil {
+		ownerName = *owner
+	}
+	if err := r.Resolver.Service.AdminSetFieldOwner(contextToken(ctx), fieldID, conquerType, ownerName); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WipeUser is the resolver for the wipeUser field.
+func (r *mutationResolver) WipeUser(ctx context.Context, username string) (bool, error) {
+	if err := r.Resolver.Service.AdminWipeUser(contextToken(ctx), username); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetRound is the resolver for the setRound field.
+func (r *mutationResolver) SetRound(ctx context.Context, startAt time.Time, endAt time.Time, freezeAt *time.Time, scoring *string) (*model.Round, error) {
+	round := apimodel.Round{
+		StartAt: startAt,
+		EndAt:   endAt,
+	}
+	if freezeAt != nil {
+		round.FreezeAt = *freezeAt
+	}
+	if scoring != nil {
+		round.Scoring = apimodel.Scoring(*scoring)
+	}
+	round, err := r.Resolver.Service.AdminSetRound(contextToken(ctx), round)
+	if err != nil {
+		return nil, err
+	}
+	return convertRound(apimodel.RoundStatus{
+		Round: round,
+		State: round.State(time.Now()),
+	}), nil
+}
+
+// Me is the resolver for the me field.
+func (r *queryResolver) Me(ctx context.Context) (string, error) {
+	return r.Resolver.Service.GetMe(contextToken(ctx))
+}
+
+// Users is the resolver for the users field.
+func (r *queryResolver) Users(ctx context.Context) ([]*model.User, error) {
+	users, err := r.Resolver.Service.GetUserList(contextToken(ctx))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*model.User, 0, len(users))
+	for _, user := range users {
+		result = append(result, &model.User{
+			ID:       user.ID,
+			Username: user.Username,
+		})
+	}
+	return result, nil
+}
+
+// Map is the resolver for the map field.
+func (r *queryResolver) Map(ctx context.Context, start int, end int) ([]*model.Field, error) {
+	// start 0 would be the whole map
+	if start < 1 || start > end {
+		return nil, apimodel.ErrInvalidRange
+	}
+	if end-start+1 > maxMapRange {
+		start = end - maxMapRange + 1
+	}
+	fieldMap, err := r.Resolver.Service.GetCurrentMap(start, end)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*model.Field, 0, len(fieldMap.Fields))
+	for _, field := range fieldMap.Fields {
+		result = append(result, convertField(field))
+	}
+	return result, nil
 }
 
 // Fields is the resolver for the fields field.
@@ -42,11 +238,22 @@ func (r *queryResolver) Fields(ctx context.Context) ([]*model.Field, error) {
 		return nil, errors.New("token is required")
 	}
 	tokenStr := token.(string)
-	fields, err := r.Resolver.Service.GetUserConquerField(tokenStr, "graphql")
+	fields, err := r.Resolver.Service.GetUserConquerField(tokenStr, r.Resolver.ConquerType)
 	if err != nil {
 		return nil, err
 	}
-	result := make([]*model.Field, 0)
+	result := make([]*model.Field, 0, len(fields))
+	// read the owners once for all fields instead of once per field
+	if slices.Contains(graphql.CollectAllFields(ctx), "owners") {
+		fieldMap, err := r.Resolver.Service.GetFields(fields)
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range fieldMap.Fields {
+			result = append(result, convertField(field))
+		}
+		return result, nil
+	}
 	for _, field := range fields {
 		result = append(result, &model.Field{
 			ID: field,
@@ -55,11 +262,185 @@ func (r *queryResolver) Fields(ctx context.Context) ([]*model.Field, error) {
 	return result, nil
 }
 
+// Sessions is the resolver for the sessions field.
+func (r *queryResolver) Sessions(ctx context.Context) ([]*model.Session, error) {
+	token := ctx.Value("token")
+	if token == nil {
+		return nil, errors.New("token is required")
+	}
+	tokenStr := token.(string)
+	sessions, err := r.Resolver.Service.GetSessions(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*model.Session, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, &model.Session{
+			ID:         session.ID,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			Current:    session.Current,
+		})
+	}
+	return result, nil
+}
+
+// Field is the resolver for the field field.
+func (r *queryResolver) Field(ctx context.Context, id int) (*model.Field, error) {
+	if err := r.Resolver.checkFieldID(id); err != nil {
+		return nil, err
+	}
+	return &model.Field{ID: id}, nil
+}
+
+// MapChanges is the resolver for the mapChanges field.
+func (r *queryResolver) MapChanges(ctx context.Context, since int) (*model.MapChanges, error) {
+	if since < 0 {
+		return nil, errors.New("since must not be negative")
+	}
+	changes, err := r.Resolver.Service.GetMapChanges(uint64(since))
+	if err != nil {
+		return nil, err
+	}
+	result := &model.MapChanges{
+		Version: int(changes.Version),
+		Changes: make([]*model.MapChange, 0, len(changes.Changes)),
+	}
+	for _, change := range changes.Changes {
+		result.Changes = append(result.Changes, &model.MapChange{
+			Version:     int(change.Version),
+			FieldID:     change.FieldID,
+			ConquerType: change.ConquerType,
+			Owner:       change.Owner,
+		})
+	}
+	return result, nil
+}
+
+// Scoreboard is the resolver for the scoreboard field.
+func (r *queryResolver) Scoreboard(ctx context.Context, offset *int, limit *int) ([]*model.Score, error) {
+	scoreList, err := r.Resolver.Service.GetScoreboard(optionalInt(offset, 0), optionalInt(limit, 0))
+	if err != nil {
+		return nil, err
+	}
+	return convertScores(scoreList), nil
+}
+
+// MyScore is the resolver for the myScore field.
+func (r *queryResolver) MyScore(ctx context.Context, around *int) (*model.ScoreNeighbourhood, error) {
+	me, err := r.Resolver.Service.GetMyScore(contextToken(ctx), optionalInt(around, defaultNeighbours))
+	if err != nil {
+		return nil, err
+	}
+	return &model.ScoreNeighbourhood{
+		Score:     convertScores([]apimodel.Score{me.Score})[0],
+		ScoreList: convertScores(me.ScoreList),
+	}, nil
+}
+
+// AdminUsers is the resolver for the adminUsers field.
+func (r *queryResolver) AdminUsers(ctx context.Context) ([]*model.UserStats, error) {
+	users, err := r.Resolver.Service.AdminListUsers(contextToken(ctx))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*model.UserStats, 0, len(users))
+	for _, user := range users {
+		result = append(result, &model.UserStats{
+			ID:                  user.ID,
+			Username:            user.Username,
+			Role:                user.Role,
+			Banned:              user.Banned,
+			ConquerFieldCount:   user.ConquerFieldCount,
+			ConquerHistoryCount: convertHistory(user.ConquerHistoryCount),
+		})
+	}
+	return result, nil
+}
+
+// AdminRound is the resolver for the adminRound field.
+func (r *queryResolver) AdminRound(ctx context.Context) (*model.Round, error) {
+	round, err := r.Resolver.Service.AdminGetRound(contextToken(ctx))
+	if err != nil {
+		if errors.Is(err, apimodel.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return convertRound(round), nil
+}
+
+// AdminAuditLog is the resolver for the adminAuditLog field.
+func (r *queryResolver) AdminAuditLog(ctx context.Context, limit *int) ([]*model.AuditEntry, error) {
+	limitValue := 0
+	if limit != nil {
+		limitValue = *limit
+	}
+	entries, err := r.Resolver.Service.AdminGetAuditLog(contextToken(ctx), limitValue)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*model.AuditEntry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, &model.AuditEntry{
+			Time:   entry.Time,
+			Admin:  entry.Admin,
+			Action: entry.Action,
+			Target: entry.Target,
+			Detail: entry.Detail,
+		})
+	}
+	return result, nil
+}
+
+// FieldConquered is the resolver for the fieldConquered field.
+func (r *subscriptionResolver) FieldConquered(ctx context.Context, start *int, end *int) (<-chan *model.FieldUpdate, error) {
+	events, err := r.Resolver.Service.SubscribeFieldUpdates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	updates := make(chan *model.FieldUpdate, 1)
+	go func() {
+		defer close(updates)
+		for event := range events {
+			if start != nil && event.FieldID < *start {
+				continue
+			}
+			if end != nil && event.FieldID > *end {
+				continue
+			}
+			select {
+			case updates <- &model.FieldUpdate{
+				FieldID:     event.FieldID,
+				ConquerType: event.ConquerType,
+				Owner:       event.Owner,
+			}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return updates, nil
+}
+
+// ScoreboardChanged is the resolver for the scoreboardChanged field.
+func (r *subscriptionResolver) ScoreboardChanged(ctx context.Context) (<-chan []*model.Score, error) {
+	return r.Resolver.scoreboards.subscribe(ctx, r.Resolver.Service)
+}
+
+// Field returns FieldResolver implementation.
+func (r *Resolver) Field() FieldResolver { return &fieldResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
+type fieldResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
@@ -0,0 +1,157 @@
+package graph
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	apimodel "github.com/zodius/api-war/model"
+	"github.com/zodius/api-war/tools/graph/model"
+)
+
+// scoreboardInterval limits how often scoreboardChanged pushes to a subscriber
+const scoreboardInterval = time.Second
+
+// waitForChanges blocks until at least one event arrives, then keeps draining
+// events until interval has passed, returns false if the subscription ended
+func waitForChanges(ctx context.Context, events <-chan apimodel.ConquerEvent, interval time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case _, ok := <-events:
+		if !ok {
+			return false
+		}
+	}
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case _, ok := <-events:
+			if !ok {
+				return false
+			}
+		case <-timer.C:
+			return true
+		}
+	}
+}
+
+// scoreboardHub reads the scoreboard once per change for every
+// scoreboardChanged subscriber of the process, it runs while there are
+// subscribers
+type scoreboardHub struct {
+	lock        sync.Mutex
+	subscribers map[chan []*model.Score]struct{}
+	latest      []*model.Score
+	stop        context.CancelFunc
+}
+
+// subscribe returns a channel with the latest scoreboard, a subscriber that
+// falls behind only gets the newest one
+func (h *scoreboardHub) subscribe(ctx context.Context, service apimodel.Service) (<-chan []*model.Score, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.stop == nil {
+		runCtx, stop := context.WithCancel(context.Background())
+		events, err := service.SubscribeFieldUpdates(runCtx)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		h.stop = stop
+		h.subscribers = make(map[chan []*model.Score]struct{})
+		h.latest = nil
+		go h.run(runCtx, service, events)
+	}
+
+	scoreboards := make(chan []*model.Score, 1)
+	if h.latest != nil {
+		scoreboards <- h.latest
+	}
+	h.subscribers[scoreboards] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		h.lock.Lock()
+		defer h.lock.Unlock()
+		delete(h.subscribers, scoreboards)
+		close(scoreboards)
+		if len(h.subscribers) == 0 {
+			h.stop()
+			h.stop = nil
+		}
+	}()
+	return scoreboards, nil
+}
+
+func (h *scoreboardHub) run(ctx context.Context, service apimodel.Service, events <-chan apimodel.ConquerEvent) {
+	// drain the subscription, the broker closes it once ctx is done
+	defer func() {
+		for range events {
+		}
+	}()
+	for {
+		// send current scoreboard, then wait for the next batch of changes
+		if scoreList, err := service.GetScoreboard(0, 0); err == nil {
+			h.broadcast(ctx, convertScores(scoreList))
+		}
+		if !waitForChanges(ctx, events, scoreboardInterval) {
+			return
+		}
+	}
+}
+
+func (h *scoreboardHub) broadcast(ctx context.Context, scores []*model.Score) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	// a restarted hub owns the subscribers now
+	if ctx.Err() != nil {
+		return
+	}
+	h.latest = scores
+	for subscriber := range h.subscribers {
+		select {
+		case <-subscriber:
+		default:
+		}
+		subscriber <- scores
+	}
+}
+
+// defaultNeighbours is the number of scores shown on each side of the caller
+const defaultNeighbours = 5
+
+func convertScores(scoreList []apimodel.Score) []*model.Score {
+	result := make([]*model.Score, 0, len(scoreList))
+	for _, score := range scoreList {
+		result = append(result, &model.Score{
+			Rank:                score.Rank,
+			Username:            score.Username,
+			ConquerFieldCount:   score.ConquerFieldCount,
+			ConquerHistoryCount: convertHistory(score.ConquerHistoryCount),
+			HoldPoints:          score.HoldPoints,
+		})
+	}
+	return result
+}
+
+// convertHistory turns a score column -> count map into a list sorted by column
+func convertHistory(historyCount map[string]int) []*model.ConquerHistory {
+	history := make([]*model.ConquerHistory, 0, len(historyCount))
+	for conquerType, count := range historyCount {
+		history = append(history, &model.ConquerHistory{
+			ConquerType: conquerType,
+			Count:       count,
+		})
+	}
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].ConquerType < history[j].ConquerType
+	})
+	return history
+}
@@ -0,0 +1,296 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: apiwar.proto
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	Battlefield_Login_FullMethodName              = "/apiwar.Battlefield/Login"
+	Battlefield_Register_FullMethodName           = "/apiwar.Battlefield/Register"
+	Battlefield_ConquerField_FullMethodName       = "/apiwar.Battlefield/ConquerField"
+	Battlefield_ListMyFields_FullMethodName       = "/apiwar.Battlefield/ListMyFields"
+	Battlefield_StreamFieldUpdates_FullMethodName = "/apiwar.Battlefield/StreamFieldUpdates"
+)
+
+// BattlefieldClient is the client API for Battlefield service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Battlefield is the gRPC conquer protocol, authenticated calls expect the
+// token from Login in the "x-api-token" metadata
+type BattlefieldClient interface {
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error)
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
+	ConquerField(ctx context.Context, in *ConquerFieldRequest, opts ...grpc.CallOption) (*ConquerFieldResponse, error)
+	ListMyFields(ctx context.Context, in *ListMyFieldsRequest, opts ...grpc.CallOption) (*ListMyFieldsResponse, error)
+	StreamFieldUpdates(ctx context.Context, in *StreamFieldUpdatesRequest, opts ...grpc.CallOption) (Battlefield_StreamFieldUpdatesClient, error)
+}
+
+type battlefieldClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBattlefieldClient(cc grpc.ClientConnInterface) BattlefieldClient {
+	return &battlefieldClient{cc}
+}
+
+func (c *battlefieldClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LoginResponse)
+	err := c.cc.Invoke(ctx, Battlefield_Login_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *battlefieldClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RegisterResponse)
+	err := c.cc.Invoke(ctx, Battlefield_Register_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *battlefieldClient) ConquerField(ctx context.Context, in *ConquerFieldRequest, opts ...grpc.CallOption) (*ConquerFieldResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConquerFieldResponse)
+	err := c.cc.Invoke(ctx, Battlefield_ConquerField_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *battlefieldClient) ListMyFields(ctx context.Context, in *ListMyFieldsRequest, opts ...grpc.CallOption) (*ListMyFieldsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListMyFieldsResponse)
+	err := c.cc.Invoke(ctx, Battlefield_ListMyFields_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *battlefieldClient) StreamFieldUpdates(ctx context.Context, in *StreamFieldUpdatesRequest, opts ...grpc.CallOption) (Battlefield_StreamFieldUpdatesClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Battlefield_ServiceDesc.Streams[0], Battlefield_StreamFieldUpdates_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &battlefieldStreamFieldUpdatesClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Battlefield_StreamFieldUpdatesClient interface {
+	Recv() (*FieldUpdate, error)
+	grpc.ClientStream
+}
+
+type battlefieldStreamFieldUpdatesClient struct {
+	grpc.ClientStream
+}
+
+func (x *battlefieldStreamFieldUpdatesClient) Recv() (*FieldUpdate, error) {
+	m := new(FieldUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// BattlefieldServer is the server API for Battlefield service.
+// All implementations must embed UnimplementedBattlefieldServer
+// for forward compatibility
+//
+// Battlefield is the gRPC conquer protocol, authenticated calls expect the
+// token from Login in the "x-api-token" metadata
+type BattlefieldServer interface {
+	Login(context.Context, *LoginRequest) (*LoginResponse, error)
+	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	ConquerField(context.Context, *ConquerFieldRequest) (*ConquerFieldResponse, error)
+	ListMyFields(context.Context, *ListMyFieldsRequest) (*ListMyFieldsResponse, error)
+	StreamFieldUpdates(*StreamFieldUpdatesRequest, Battlefield_StreamFieldUpdatesServer) error
+	mustEmbedUnimplementedBattlefieldServer()
+}
+
+// UnimplementedBattlefieldServer must be embedded to have forward compatible implementations.
+type UnimplementedBattlefieldServer struct {
+}
+
+func (UnimplementedBattlefieldServer) Login(context.Context, *LoginRequest) (*LoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (UnimplementedBattlefieldServer) Register(context.Context, *RegisterRequest) (*RegisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedBattlefieldServer) ConquerField(context.Context, *ConquerFieldRequest) (*ConquerFieldResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConquerField not implemented")
+}
+func (UnimplementedBattlefieldServer) ListMyFields(context.Context, *ListMyFieldsRequest) (*ListMyFieldsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMyFields not implemented")
+}
+func (UnimplementedBattlefieldServer) StreamFieldUpdates(*StreamFieldUpdatesRequest, Battlefield_StreamFieldUpdatesServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamFieldUpdates not implemented")
+}
+func (UnimplementedBattlefieldServer) mustEmbedUnimplementedBattlefieldServer() {}
+
+// UnsafeBattlefieldServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BattlefieldServer will
+// result in compilation errors.
+type UnsafeBattlefieldServer interface {
+	mustEmbedUnimplementedBattlefieldServer()
+}
+
+func RegisterBattlefieldServer(s grpc.ServiceRegistrar, srv BattlefieldServer) {
+	s.RegisterService(&Battlefield_ServiceDesc, srv)
+}
+
+func _Battlefield_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BattlefieldServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Battlefield_Login_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BattlefieldServer).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Battlefield_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BattlefieldServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Battlefield_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BattlefieldServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Battlefield_ConquerField_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConquerFieldRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BattlefieldServer).ConquerField(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Battlefield_ConquerField_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BattlefieldServer).ConquerField(ctx, req.(*ConquerFieldRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Battlefield_ListMyFields_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMyFieldsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BattlefieldServer).ListMyFields(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Battlefield_ListMyFields_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BattlefieldServer).ListMyFields(ctx, req.(*ListMyFieldsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Battlefield_StreamFieldUpdates_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamFieldUpdatesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BattlefieldServer).StreamFieldUpdates(m, &battlefieldStreamFieldUpdatesServer{ServerStream: stream})
+}
+
+type Battlefield_StreamFieldUpdatesServer interface {
+	Send(*FieldUpdate) error
+	grpc.ServerStream
+}
+
+type battlefieldStreamFieldUpdatesServer struct {
+	grpc.ServerStream
+}
+
+func (x *battlefieldStreamFieldUpdatesServer) Send(m *FieldUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Battlefield_ServiceDesc is the grpc.ServiceDesc for Battlefield service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Battlefield_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "apiwar.Battlefield",
+	HandlerType: (*BattlefieldServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Login",
+			Handler:    _Battlefield_Login_Handler,
+		},
+		{
+			MethodName: "Register",
+			Handler:    _Battlefield_Register_Handler,
+		},
+		{
+			MethodName: "ConquerField",
+			Handler:    _Battlefield_ConquerField_Handler,
+		},
+		{
+			MethodName: "ListMyFields",
+			Handler:    _Battlefield_ListMyFields_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamFieldUpdates",
+			Handler:       _Battlefield_StreamFieldUpdates_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "apiwar.proto",
+}
@@ -12,6 +12,11 @@ class NormalUser(HttpUser):
         with self.client.post("/api/v1/register", json={"username": self.username, "password": self.password}, catch_response=True) as resp:
             if resp.status_code != 200:
                 raise StopUser()
+        # tokens slide on every use, one login lasts the whole run
+        self.token = self.login()
+
+    def on_stop(self):
+        self.client.post("/api/v1/logout", headers={"X-Api-Token": self.token})
     
     def login(self):
         with self.client.post("/api/v1/login", json={"username": self.username, "password": self.password}, catch_response=True) as resp:
@@ -21,12 +26,12 @@ class NormalUser(HttpUser):
     
     @task
     def get_me(self):
-        token = self.login()
+        token = self.token
         self.client.get("/me", headers={"X-Api-Token": token})
     
     @task
     def get_userlist(self):
-        token = self.login()
+        token = self.token
         self.client.get("/api/v1/userlist", headers={"X-Api-Token": token})
     
     @task
@@ -39,13 +44,13 @@ class NormalUser(HttpUser):
     
     @task(20)
     def restful_get_user_conquer_fields(self):
-        token = self.login()
+        token = self.token
         self.client.get("/api/v1/fields", headers={"X-Api-Token": token})
 
     @task(20)
     def restful_conquer_field(self):
-        token = self.login()
-        with self.client.rename_request("/api/v1/conquer/[id]"):
-            for _ in range(5000):
-                id = random.randint(1, 1000000)
-                self.client.post(f"/api/v1/conquer/{id}", headers={"X-Api-Token": token})
+        token = self.token
+        # 5000 fields in batches of the default conquer batch size
+        for _ in range(5000 // 40):
+            ids = [random.randint(1, 1000000) for _ in range(40)]
+            self.client.post("/api/v1/conquer", json={"fieldIDs": ids}, headers={"X-Api-Token": token})